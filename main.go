@@ -3,9 +3,14 @@ package main
 import (
 	sb "github.com/Petrify/hskl-bot/school-discord"
 	"github.com/Petrify/simp-core"
+	simpsql "github.com/Petrify/simp-core/sql"
 )
 
 func main() {
 	sb.Start()
 	simp.Wait()
+
+	// the database goes last, stopping the bot still writes to it
+	sb.Stop()
+	simpsql.DB.Close()
 }
@@ -0,0 +1,191 @@
+package schooldiscord
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// argError is an argument the user got wrong. It is answered with msg, filled in with arg
+type argError struct {
+	msg msgKey
+	arg string
+}
+
+func (e argError) Error() string {
+	return "invalid argument " + e.arg
+}
+
+func (e argError) message(lang locale) string {
+	return tr(lang, e.msg, e.arg)
+}
+
+// splitArgs splits input into words at whitespace. Text in double quotes is kept together as a single word
+func splitArgs(input string) ([]string, error) {
+	words := make([]string, 0)
+	cur := strings.Builder{}
+	inWord, quoted := false, false
+
+	for _, r := range input {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inWord = true
+		case unicode.IsSpace(r) && !quoted:
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+			}
+			inWord = false
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quoted {
+		return nil, argError{msgArgUnclosedQuote, input}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+// argSpec lists the flags and options a command understands
+type argSpec struct {
+	flags   []string          // switches without a value, given as `--name`
+	options []string          // given as `--name value` or `name:value`
+	aliases map[string]string // other names of options, e.g. sem for semester
+}
+
+// cmdArgs are the arguments of a command, split up according to an argSpec
+type cmdArgs struct {
+	pos   []string // everything that is neither a flag nor an option, in order
+	flags map[string]bool
+	opts  map[string]string
+}
+
+// parse splits args into positional arguments, flags and options. Flag and option names are case insensitive.
+// A `key:value` argument with a key the spec does not know is a positional argument,
+// a `--name` the spec does not know is an error
+func (spec argSpec) parse(args []string) (cmdArgs, error) {
+	a := cmdArgs{
+		pos:   make([]string, 0, len(args)),
+		flags: make(map[string]bool),
+		opts:  make(map[string]string),
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			name := spec.name(arg[2:])
+			switch {
+			case spec.isFlag(name):
+				a.flags[name] = true
+			case spec.isOption(name):
+				if i+1 == len(args) {
+					return a, argError{msgArgMissingValue, arg}
+				}
+				i++
+				a.opts[name] = args[i]
+			default:
+				return a, argError{msgArgUnknownFlag, arg}
+			}
+			continue
+		}
+
+		if k := strings.Index(arg, ":"); k > 0 {
+			if name := spec.name(arg[:k]); spec.isOption(name) {
+				if k == len(arg)-1 {
+					return a, argError{msgArgMissingValue, arg}
+				}
+				a.opts[name] = arg[k+1:]
+				continue
+			}
+		}
+
+		a.pos = append(a.pos, arg)
+	}
+	return a, nil
+}
+
+// returns the lowercased name of a flag or option, resolving aliases
+func (spec argSpec) name(s string) string {
+	s = strings.ToLower(s)
+	if n, ok := spec.aliases[s]; ok {
+		return n
+	}
+	return s
+}
+
+func (spec argSpec) isFlag(name string) bool {
+	for _, f := range spec.flags {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (spec argSpec) isOption(name string) bool {
+	for _, o := range spec.options {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+// require checks that there are at least min and, unless max is negative, at most max positional arguments
+func (a cmdArgs) require(min int, max int) error {
+	if len(a.pos) < min || (max >= 0 && len(a.pos) > max) {
+		return errUsage
+	}
+	return nil
+}
+
+// positional parses args of a command without flags or options, of which there have to be between min and max
+func positional(args []string, min int, max int) ([]string, error) {
+	a, err := argSpec{}.parse(args)
+	if err != nil {
+		return nil, err
+	}
+	return a.pos, a.require(min, max)
+}
+
+// intOpt returns the value of option name as a number of at least 1, or 0 if the option was not given
+func (a cmdArgs) intOpt(name string) (int, error) {
+	v, ok := a.opts[name]
+	if !ok {
+		return 0, nil
+	}
+	return parseCount(v)
+}
+
+// parseCount parses a number of at least 1, like a semester or a page
+func parseCount(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n <= 0 {
+		return 0, argError{msgArgNotANumber, arg}
+	}
+	return n, nil
+}
+
+// parseID parses the ID of a final
+func parseID(arg string) (int64, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id < 0 {
+		return 0, argError{msgArgNotAnID, arg}
+	}
+	return id, nil
+}
+
+// parseSnowflake checks that arg is a discord ID, like that of a user or channel
+func parseSnowflake(arg string) (string, error) {
+	if _, err := strconv.ParseUint(arg, 10, 64); err != nil {
+		return "", argError{msgArgNotAnID, arg}
+	}
+	return arg, nil
+}
@@ -0,0 +1,118 @@
+package schooldiscord
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+		err   error
+	}{
+		{"", []string{}, nil},
+		{"  search  MA1 ", []string{"search", "MA1"}, nil},
+		{`join "Mathematik 1" MA2`, []string{"join", "Mathematik 1", "MA2"}, nil},
+		{`a"b c"d`, []string{"ab cd"}, nil},
+		{`say "" x`, []string{"say", "", "x"}, nil},
+		{`""`, []string{""}, nil},
+		{`join "Mathematik 1`, nil, argError{msgArgUnclosedQuote, `join "Mathematik 1`}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := splitArgs(tc.input)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestArgSpecParse(t *testing.T) {
+	spec := argSpec{
+		flags:   []string{"all"},
+		options: []string{"semester", "major"},
+		aliases: map[string]string{"sem": "semester"},
+	}
+
+	tests := []struct {
+		name  string
+		args  []string
+		pos   []string
+		flags map[string]bool
+		opts  map[string]string
+		err   error
+	}{
+		{"positional", []string{"Mathematik", "1"}, []string{"Mathematik", "1"}, nil, nil, nil},
+		{"flag", []string{"--all", "x"}, []string{"x"}, map[string]bool{"all": true}, nil, nil},
+		{"flag in upper case", []string{"--ALL"}, nil, map[string]bool{"all": true}, nil, nil},
+		{"option with value", []string{"--major", "INF"}, nil, nil, map[string]string{"major": "INF"}, nil},
+		{"key value option", []string{"major:INF"}, nil, nil, map[string]string{"major": "INF"}, nil},
+		{"alias", []string{"--sem", "2"}, nil, nil, map[string]string{"semester": "2"}, nil},
+		{"key value alias", []string{"SEM:2"}, nil, nil, map[string]string{"semester": "2"}, nil},
+		{"unknown key is positional", []string{"room:5"}, []string{"room:5"}, nil, nil, nil},
+		{"bare dashes are positional", []string{"--"}, []string{"--"}, nil, nil, nil},
+		{"unknown flag", []string{"x", "--verbose"}, nil, nil, nil, argError{msgArgUnknownFlag, "--verbose"}},
+		{"option without value", []string{"--major"}, nil, nil, nil, argError{msgArgMissingValue, "--major"}},
+		{"key without value", []string{"sem:"}, nil, nil, nil, argError{msgArgMissingValue, "sem:"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := spec.parse(tc.args)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+			if err != nil {
+				return
+			}
+
+			if tc.pos == nil {
+				tc.pos = []string{}
+			}
+			if tc.flags == nil {
+				tc.flags = map[string]bool{}
+			}
+			if tc.opts == nil {
+				tc.opts = map[string]string{}
+			}
+			if !reflect.DeepEqual(a.pos, tc.pos) {
+				t.Errorf("positional: got %q, want %q", a.pos, tc.pos)
+			}
+			if !reflect.DeepEqual(a.flags, tc.flags) {
+				t.Errorf("flags: got %v, want %v", a.flags, tc.flags)
+			}
+			if !reflect.DeepEqual(a.opts, tc.opts) {
+				t.Errorf("options: got %v, want %v", a.opts, tc.opts)
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	tests := []struct {
+		pos      int
+		min, max int
+		err      error
+	}{
+		{0, 0, 0, nil},
+		{1, 0, 0, errUsage},
+		{0, 1, 2, errUsage},
+		{2, 1, 2, nil},
+		{3, 1, 2, errUsage},
+		{5, 1, -1, nil},
+	}
+
+	for _, tc := range tests {
+		a := cmdArgs{pos: make([]string, tc.pos)}
+		if err := a.require(tc.min, tc.max); err != tc.err {
+			t.Errorf("%d arguments, between %d and %d: got %v, want %v", tc.pos, tc.min, tc.max, err, tc.err)
+		}
+	}
+}
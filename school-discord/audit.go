@@ -0,0 +1,243 @@
+package schooldiscord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// actions recorded in a guild's audit log
+const (
+	auditJoin    = "join"
+	auditLeave   = "leave"
+	auditSetting = "setting"
+	auditReindex = "reindex"
+	auditKill    = "kill"
+	auditVoice   = "voice"
+)
+
+// the most events the audit command shows
+const auditQueryLimit = 500
+
+// how long mirroring an event to the audit channel may take, including retries
+const auditMirrorTimeout = 30 * time.Second
+
+const auditDateFormat = "2006-01-02"
+
+// auditEvent is an entry of a guild's audit log
+type auditEvent struct {
+	id     int64
+	time   time.Time
+	actor  string // the user who acted
+	action string
+	target string // what was acted on, like final:42 or option:language
+	before string // the value before a change, if the action changed one
+	after  string
+}
+
+// auditFilter selects events of the audit log. Empty fields match every event
+type auditFilter struct {
+	user   string // events by or about the user
+	target string
+	action string
+	since  time.Time
+	until  time.Time // exclusive
+}
+
+func finalTarget(finalID int) string {
+	return fmt.Sprintf("final:%d", finalID)
+}
+
+func userTarget(userID string) string {
+	return "user:" + userID
+}
+
+func optionTarget(key string) string {
+	return "option:" + key
+}
+
+// audit records ev in g's audit log and mirrors it to g's audit channel, if it has one.
+// The action has already happened, so failing to record it is only logged
+func (s *Service) audit(g *guild, ev auditEvent) {
+	ev.time = s.clock.Now()
+	if err := insertAuditEvent(g, ev); err != nil {
+		g.log.Error("Could not record audit event", "actor", ev.actor, "action", ev.action, "target", ev.target, "err", err)
+	}
+
+	chanID := g.auditChannel()
+	if chanID == "" || !s.beginWork() {
+		return
+	}
+	// the command that acted does not wait for discord to take the message
+	go func() {
+		defer s.endWork()
+		s.mirrorAuditEvent(g, chanID, ev)
+	}()
+}
+
+func (s *Service) mirrorAuditEvent(g *guild, chanID string, ev auditEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), auditMirrorTimeout)
+	defer cancel()
+
+	err := retry(ctx, func() error {
+		_, err := s.ds.ChannelMessageSendComplex(chanID, &discordgo.MessageSend{
+			Content: ev.mirrorLine(),
+			// the log channel should not ping everyone who joins a final
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		})
+		return err
+	})
+	if err != nil {
+		g.log.Warn("Could not mirror audit event", "channel", chanID, "err", err)
+	}
+}
+
+// setGuildOption saves an option of g and records the change in its audit log
+func (s *Service) setGuildOption(g *guild, key string, before string, after string, actorID string) error {
+	if err := setOption(g, key, after, actorID); err != nil {
+		return err
+	}
+	s.audit(g, auditEvent{actor: actorID, action: auditSetting, target: optionTarget(key), before: before, after: after})
+	return nil
+}
+
+func (ev auditEvent) change() string {
+	if ev.before == "" && ev.after == "" {
+		return ""
+	}
+	return ev.before + " -> " + ev.after
+}
+
+// the message an event is mirrored to the audit channel as
+func (ev auditEvent) mirrorLine() string {
+	line := fmt.Sprintf("`%s` %s **%s** `%s`", ev.time.Format("2006-01-02 15:04"), mention(ev.actor), ev.action, ev.target)
+	if c := ev.change(); c != "" {
+		line += " (" + c + ")"
+	}
+	return line
+}
+
+// the line an event is listed with by the audit command, matching msgAuditTableHeader
+func (ev auditEvent) tableLine() string {
+	return fmt.Sprintf("%-16s | %-20s | %-8s | %-22s | %s\n",
+		ev.time.Local().Format("2006-01-02 15:04"), ev.actor, ev.action, ev.target, ev.change())
+}
+
+var auditSpec = argSpec{
+	options: []string{"user", "final", "action", "since", "until"},
+	aliases: map[string]string{"from": "since", "to": "until"},
+}
+
+// auditFilterFrom builds the filter given to the audit command
+func auditFilterFrom(a cmdArgs) (f auditFilter, err error) {
+	if v, ok := a.opts["user"]; ok {
+		if f.user, err = parseSnowflake(strings.Trim(v, "<@!>")); err != nil {
+			return
+		}
+	}
+	if v, ok := a.opts["final"]; ok {
+		id, err := parseID(v)
+		if err != nil {
+			return f, err
+		}
+		f.target = finalTarget(int(id))
+	}
+	f.action = strings.ToLower(a.opts["action"])
+
+	if v, ok := a.opts["since"]; ok {
+		if f.since, err = parseDate(v); err != nil {
+			return
+		}
+	}
+	if v, ok := a.opts["until"]; ok {
+		if f.until, err = parseDate(v); err != nil {
+			return
+		}
+		// until includes the whole day
+		f.until = f.until.AddDate(0, 0, 1)
+	}
+	return
+}
+
+// parseDate parses a day in the bot's time zone
+func parseDate(arg string) (time.Time, error) {
+	d, err := time.ParseInLocation(auditDateFormat, arg, time.Local)
+	if err != nil {
+		return time.Time{}, argError{msgArgNotADate, arg}
+	}
+	return d, nil
+}
+
+func cmdAudit(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	a, err := auditSpec.parse(args)
+	if err != nil {
+		return err
+	}
+	if err = a.require(0, 0); err != nil {
+		return err
+	}
+	f, err := auditFilterFrom(a)
+	if err != nil {
+		return err
+	}
+
+	events, err := getAuditEvents(t.origin, f, auditQueryLimit)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return t.PrintMsg(msgAuditEmpty)
+	}
+
+	lines := make([]string, len(events))
+	for i, ev := range events {
+		lines[i] = ev.tableLine()
+	}
+	return t.PrintPaged(tr(t.lang, msgAuditList), tr(t.lang, msgAuditTableHeader), lines)
+}
+
+func cmdAuditChannel(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+	g := t.origin
+
+	args, err := positional(args, 0, 1)
+	if err != nil {
+		return err
+	}
+	current := g.auditChannel()
+	if len(args) == 0 {
+		if current == "" {
+			return t.PrintMsg(msgAuditChannelNone)
+		}
+		return t.PrintMsg(msgAuditChannelCurrent, "<#"+current+">")
+	}
+
+	chanID := ""
+	if !strings.EqualFold(args[0], "off") {
+		// channels may be given as a mention, <#ID>
+		chanID, err = parseSnowflake(strings.Trim(args[0], "<#>"))
+		if err != nil {
+			return err
+		}
+		ch, err := t.serv.ds.Channel(chanID)
+		if err != nil || ch.GuildID != g.id() || ch.Type != discordgo.ChannelTypeGuildText {
+			return t.PrintMsg(msgAuditChannelInvalid, args[0])
+		}
+	}
+
+	err = t.serv.setGuildOption(g, "audit_channel", current, chanID, c.Author().ID)
+	if err != nil {
+		return err
+	}
+
+	g.setAuditChannel(chanID)
+	if chanID == "" {
+		return t.PrintMsg(msgAuditChannelOff)
+	}
+	return t.PrintMsg(msgAuditChannelSet, "<#"+chanID+">")
+}
@@ -0,0 +1,58 @@
+package schooldiscord
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAuditEventUsesClockAndMirrorsInBackground(t *testing.T) {
+	var mu sync.Mutex
+	var recorded []driver.Value
+	onSQL(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		if strings.Contains(query, "INSERT INTO audit_event") {
+			mu.Lock()
+			recorded = args
+			mu.Unlock()
+		}
+		return nil, nil
+	})
+	clk := newFakeClock()
+	s, g, dc := newTestService(t, clk)
+	g.setAuditChannel("900")
+
+	// discord takes its time with the audit channel
+	release := make(chan struct{})
+	dc.respond = func(req *http.Request) (int, string) {
+		if strings.Contains(req.URL.Path, "/channels/900/") {
+			<-release
+		}
+		return 0, ""
+	}
+
+	returned := make(chan struct{})
+	go func() {
+		s.audit(g, auditEvent{actor: testUser, action: auditJoin, target: finalTarget(5)})
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("audit waited for the audit channel")
+	}
+
+	close(release)
+	s.work.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(recorded) == 0 || recorded[0] != "2021-03-01 12:00:00" {
+		t.Errorf("event was not recorded with the time of the clock, args: %v", recorded)
+	}
+	if !dc.sentContaining("`2021-03-01 12:00` <@" + testUser + "> **join** `final:5`") {
+		t.Errorf("event was not mirrored, sent: %q", dc.messages())
+	}
+}
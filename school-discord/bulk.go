@@ -0,0 +1,182 @@
+package schooldiscord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// a single final of a bulk join or leave
+type bulkItem struct {
+	arg    string
+	final  *modelFinal
+	status msgKey
+}
+
+// resolveBulk resolves the arguments of a bulk join or leave to finals.
+// Arguments are either a list of IDs, or `all` followed by search filters.
+// If the arguments can not be resolved at all, the returned msgKey describes why
+func (t *terminal) resolveBulk(args cmdArgs, userID string, leaving bool) ([]*bulkItem, msgKey, error) {
+
+	if !strings.EqualFold(args.pos[0], "all") {
+		if len(args.opts) > 0 {
+			return nil, msgBulkAllUsage, nil
+		}
+
+		items := make([]*bulkItem, len(args.pos))
+		for i, a := range args.pos {
+			items[i] = &bulkItem{arg: a}
+
+			id, err := parseID(a)
+			if err != nil {
+				items[i].status = msgBulkNotAnID
+				continue
+			}
+
+			items[i].final, err = t.serv.getFinal(id, t.origin)
+			if err != nil {
+				return nil, "", err
+			}
+			if items[i].final == nil {
+				items[i].status = msgBulkNotFound
+			}
+		}
+		return items, "", nil
+	}
+
+	if len(args.pos) > 1 {
+		return nil, msgBulkAllUsage, nil
+	}
+	filter, err := filterFrom(args)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// joining the whole catalog is never what anyone wants
+	if !leaving && filter.empty() {
+		return nil, msgBulkAllUsage, nil
+	}
+
+	ctlg, err := t.serv.catalog(t.origin, filter)
+	if err != nil {
+		return nil, "", err
+	}
+	inFilter := make(map[int]bool, len(ctlg.finals))
+	for _, f := range ctlg.finals {
+		inFilter[f.id] = true
+	}
+
+	items := make([]*bulkItem, 0)
+	if leaving {
+		lst, err := getUserFinals(t.origin, userID)
+		if err != nil {
+			return nil, "", err
+		}
+		for i := range lst {
+			if inFilter[lst[i].id] {
+				items = append(items, &bulkItem{arg: strconv.Itoa(lst[i].id), final: &lst[i]})
+			}
+		}
+		return items, "", nil
+	}
+
+	for _, f := range ctlg.finals {
+		mf, err := t.serv.getFinal(int64(f.id), t.origin)
+		if err != nil {
+			return nil, "", err
+		}
+		if mf != nil {
+			items = append(items, &bulkItem{arg: strconv.Itoa(f.id), final: mf})
+		}
+	}
+	return items, "", nil
+}
+
+// runBulk joins or leaves every resolved final and prints a summary of the results
+func (t *terminal) runBulk(ctx context.Context, items []*bulkItem, userID string, leaving bool) error {
+
+	for _, it := range items {
+		if it.final == nil {
+			continue
+		}
+
+		// the guild's job queue backs off when discord limits the rate of role changes
+		if ctx.Err() != nil {
+			it.status = msgBulkFailed
+			continue
+		}
+
+		var err error
+		if leaving {
+			err = t.serv.leaveFinal(ctx, t.origin, it.final, userID, t.PrintMsg)
+		} else {
+			err = t.serv.joinFinal(ctx, t.origin, it.final, userID, t.PrintMsg)
+		}
+
+		switch {
+		case err == nil:
+			it.status = msgBulkJoined
+			if leaving {
+				it.status = msgBulkLeft
+			}
+		case errors.As(err, &AlreadyJoinedError{}):
+			it.status = msgBulkAlreadyJoined
+		case errors.As(err, &NotJoinedError{}):
+			it.status = msgBulkNotJoined
+		default:
+			t.log().Error("Bulk operation failed", "final", it.final.id, "member", userID, "err", err)
+			it.status = msgBulkFailed
+		}
+	}
+
+	lines := make([]string, len(items))
+	for i, it := range items {
+		name := ""
+		if it.final != nil {
+			name = it.final.name
+		}
+		lines[i] = fmt.Sprintf("%-6s | %-16s | %s\n", it.arg, tr(t.lang, it.status), name)
+	}
+	return t.PrintPaged(tr(t.lang, msgBulkSummary), "\n", lines)
+}
+
+// bulkJoinLeave handles the bulk forms of join and leave, asking the user to confirm first
+func (t *terminal) bulkJoinLeave(ctx context.Context, args cmdArgs, userID string, leaving bool) error {
+
+	items, usage, err := t.resolveBulk(args, userID, leaving)
+	if err != nil {
+		return err
+	}
+	if usage != "" {
+		return t.PrintMsg(usage)
+	}
+	if len(items) == 0 {
+		f, _ := filterFrom(args) // already checked by resolveBulk
+		key := append(append([]string{}, args.pos...), f.describe()...)
+		return t.PrintMsg(msgNoResults, strings.Join(key, " "))
+	}
+
+	prompt := msgBulkConfirmJoin
+	if leaving {
+		prompt = msgBulkConfirmLeave
+	}
+
+	list := strings.Builder{}
+	n := 0
+	for _, it := range items {
+		if it.final != nil {
+			list.WriteString(fmt.Sprintf("[%4d] | %s\n", it.final.id, it.final.name))
+			n++
+		}
+	}
+	if n == 0 {
+		return t.runBulk(ctx, items, userID, leaving)
+	}
+
+	t.confirm(func(ctx context.Context) error {
+		return t.runBulk(ctx, items, userID, leaving)
+	})
+	return t.Print(tr(t.lang, prompt, n), codeBlockTag, "\n", list.String(), codeBlockTag)
+}
@@ -0,0 +1,96 @@
+package schooldiscord
+
+import (
+	"context"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// where the commands of a commandSet are run
+type cmdScope int
+
+const (
+	scopeGuild    cmdScope = iota // in a guild channel, with the guild's command prefix
+	scopeTerminal                 // in a terminal
+)
+
+type guildCmdFunc = func(ctx context.Context, args []string, c *GuildCommandContext) error
+type termCmdFunc = func(ctx context.Context, args []string, c *TerminalCommandContext) error
+
+// cmdContext is what every command is run with, whatever its scope
+type cmdContext interface {
+	Author() *discordgo.User
+	Lang() locale
+	Reply(text string) error
+	ReplyMsg(key msgKey, a ...interface{}) error
+}
+
+// GuildCommandContext is what a command sent to a guild channel is run with
+type GuildCommandContext struct {
+	serv *Service
+	g    *guild
+	msg  *discordgo.MessageCreate
+}
+
+func (c *GuildCommandContext) Author() *discordgo.User {
+	return c.msg.Author
+}
+
+// the locale to answer the author in
+func (c *GuildCommandContext) Lang() locale {
+	return c.g.userLocale(c.msg.Author.ID)
+}
+
+// log returns a logger that adds the guild, author and channel of the command to every line
+func (c *GuildCommandContext) log() *logger {
+	return c.g.log.With("user", c.msg.Author.ID, "channel", c.msg.ChannelID)
+}
+
+// answers on the channel the command was sent to
+func (c *GuildCommandContext) Reply(text string) (err error) {
+	for _, chunk := range splitMessage(text, msgLimit) {
+		err = retry(context.Background(), func() error {
+			_, err := c.serv.ds.ChannelMessageSend(c.msg.ChannelID, chunk)
+			return err
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (c *GuildCommandContext) ReplyMsg(key msgKey, a ...interface{}) error {
+	return c.Reply(tr(c.Lang(), key, a...))
+}
+
+// TerminalCommandContext is what a command entered in a terminal is run with
+type TerminalCommandContext struct {
+	term *terminal
+	msg  *discordgo.MessageCreate
+}
+
+func (c *TerminalCommandContext) Author() *discordgo.User {
+	return c.msg.Author
+}
+
+func (c *TerminalCommandContext) Lang() locale {
+	return c.term.lang
+}
+
+// the guild the terminal currently works on
+func (c *TerminalCommandContext) Guild() *guild {
+	return c.term.origin
+}
+
+func (c *TerminalCommandContext) Serv() *Service {
+	return c.term.serv
+}
+
+func (c *TerminalCommandContext) Reply(text string) error {
+	return c.term.Print(text)
+}
+
+func (c *TerminalCommandContext) ReplyMsg(key msgKey, a ...interface{}) error {
+	return c.term.PrintMsg(key, a...)
+}
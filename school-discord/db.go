@@ -1,10 +1,16 @@
 package schooldiscord
 
 import (
-	"github.com/Petrify/simp-core/service"
+	"database/sql"
+	"strings"
+	"time"
+
 	simpsql "github.com/Petrify/simp-core/sql"
 )
 
+// format of DATETIME columns, which are always stored in UTC
+const sqlTimeFormat = "2006-01-02 15:04:05"
+
 type modelModule struct {
 	id    int
 	name  string
@@ -14,10 +20,12 @@ type modelModule struct {
 
 //a model final but minimized for searchability
 type modelFinalSearchable struct {
-	id     int
-	name   string
-	abbr   string
-	majors []string
+	id        int
+	name      string
+	abbr      string
+	typ       string
+	majors    []string
+	semesters []int //semester of the final in the major of the same index
 }
 
 type modelFinal struct {
@@ -28,28 +36,140 @@ type modelFinal struct {
 	roleID    string
 }
 
+type modelMajor struct {
+	abbr string
+	name string
+}
+
+type modelSession struct {
+	chanID  string
+	userID  string
+	guildID string
+	kind    termKind
+	private bool
+	expires time.Time
+}
+
 type modelUser struct {
 	id       string
 	finalIDs []int
 }
 
-func (s *Service) loadSettings(g *guild) error {
+func (s *Service) loadSettings(g *guild) (err error) {
+	defer observeDB("loadSettings", &err)()
 	tx, err := simpsql.UsingSchema(g.dbSchema)
 	if err != nil {
 		return err
 	}
 
+	defer tx.Commit()
+
 	row := tx.QueryRow("SELECT `value` FROM `option` WHERE `key` = 'command_prefix'")
 	if err = row.Scan(&g.cmdPrefix); err != nil {
 		return err
 	}
 
+	// options added after a guild's first time setup may be missing
+	lang, err := optionOrDefault(tx, "language", string(defaultLocale))
+	if err != nil {
+		return err
+	}
+	g.lang = defaultLocale
+	if l, ok := parseLocale(lang); ok {
+		g.lang = l
+	}
+
+	mode, err := optionOrDefault(tx, "terminal_mode", string(termModeAuto))
+	if err != nil {
+		return err
+	}
+	g.termMode = termModeAuto
+	if m, ok := parseTermMode(mode); ok {
+		g.termMode = m
+	}
+
+	g.auditChanID, err = optionOrDefault(tx, "audit_channel", "")
+	if err != nil {
+		return err
+	}
+
+	voice, err := optionOrDefault(tx, "final_voice", "off")
+	if err != nil {
+		return err
+	}
+	g.finalVoice = voice == "on"
+
 	return nil
 }
 
+// upgradeSchema adds what was added to the guild schema since g was first set up. Its statements do nothing if run twice
+func upgradeSchema(g *guild) (err error) {
+	defer observeDB("upgradeSchema", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return err
+	}
+
+	if err = simpsql.ExecScript(tx, "sd_guild_upgrade.sql"); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// upgradeServiceSchema adds what was added to the service schema since the service was first set up.
+// Setup only runs for new services, so this runs on every start. Its statements do nothing if run twice
+func upgradeServiceSchema(s *Service) (err error) {
+	defer observeDB("upgradeServiceSchema", &err)()
+
+	tx, err := simpsql.UsingSchema(s.dbSchema)
+	if err != nil {
+		return err
+	}
+
+	if err = simpsql.ExecScript(tx, "sd_service_upgrade.sql"); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// reads an option that might not exist in the schema of older guilds
+func optionOrDefault(tx *sql.Tx, key string, def string) (string, error) {
+	var val sql.NullString
+	row := tx.QueryRow("SELECT `value` FROM `option` WHERE `key` = ?", key)
+	if err := row.Scan(&val); err == sql.ErrNoRows || (err == nil && !val.Valid) {
+		return def, nil
+	} else if err != nil {
+		return "", err
+	}
+	return val.String, nil
+}
+
+func setOption(g *guild, key string, value string, setBy string) (err error) {
+	defer observeDB("setOption", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO `option` (`key`, `value`, `set_by`) VALUES (?,?,?) "+
+			"ON DUPLICATE KEY UPDATE `value` = VALUES(`value`), `set_by` = VALUES(`set_by`)",
+		key, value, setBy)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (s *Service) getToken() error {
 
-	tx, err := simpsql.UsingSchema(service.Schema(s))
+	tx, err := simpsql.UsingSchema(s.dbSchema)
 	if err != nil {
 		return err
 	}
@@ -59,10 +179,26 @@ func (s *Service) getToken() error {
 		return err
 	}
 
+	// monitoring is off unless an address is set
+	s.metricsAddr, err = optionOrDefault(tx, "metrics_addr", "")
+	if err != nil {
+		return err
+	}
+
+	level, err := optionOrDefault(tx, "log_level", "info")
+	if err != nil {
+		return err
+	}
+	format, err := optionOrDefault(tx, "log_format", "text")
+	if err != nil {
+		return err
+	}
+	s.log.configure(level, format)
 	return nil
 }
 
-func (s *Service) getModuleCatalog(g *guild) ([]modelFinalSearchable, error) {
+func (s *Service) getModuleCatalog(g *guild) (_ []modelFinalSearchable, err error) {
+	defer observeDB("getModuleCatalog", &err)()
 
 	tx, err := simpsql.UsingSchema(g.dbSchema)
 	if err != nil {
@@ -70,42 +206,50 @@ func (s *Service) getModuleCatalog(g *guild) ([]modelFinalSearchable, error) {
 	}
 
 	rows, err := tx.Query(
-		`SELECT idfinal, module.name, module.abbr, module.fk_major FROM final
+		`SELECT idfinal, final.type, module.name, module.abbr, module.fk_major, module.semester FROM final
 		JOIN module ON idfinal = module.fk_idfinal 
         GROUP BY idfinal, module.abbr, module.fk_major
         ORDER BY idfinal`)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	var lst []modelFinalSearchable = make([]modelFinalSearchable, 0)
 
-	var tmpID int
+	var tmpID, tmpSemester int
 	var lastModel = modelFinalSearchable{id: -1}
-	var tmpName, tmpAbbr, tmpMajor string
+	var tmpType, tmpName, tmpAbbr, tmpMajor string
 	for rows.Next() {
 
-		err := rows.Scan(&tmpID, &tmpName, &tmpAbbr, &tmpMajor)
+		err := rows.Scan(&tmpID, &tmpType, &tmpName, &tmpAbbr, &tmpMajor, &tmpSemester)
 		if err == nil {
 			if tmpID != lastModel.id {
 				if lastModel.id != -1 {
 					lst = append(lst, lastModel)
 				}
 				lastModel = modelFinalSearchable{
-					id:     tmpID,
-					name:   tmpName,
-					abbr:   tmpAbbr,
-					majors: make([]string, 0, 1),
+					id:        tmpID,
+					name:      tmpName,
+					abbr:      tmpAbbr,
+					typ:       tmpType,
+					majors:    make([]string, 0, 1),
+					semesters: make([]int, 0, 1),
 				}
 			}
 			lastModel.majors = append(lastModel.majors, tmpMajor)
+			lastModel.semesters = append(lastModel.semesters, tmpSemester)
 		}
 	}
+	if lastModel.id != -1 {
+		lst = append(lst, lastModel)
+	}
 
 	return lst, nil
 }
 
-func (s *Service) getFinal(id int64, g *guild) (*modelFinal, error) {
+func (s *Service) getFinal(id int64, g *guild) (_ *modelFinal, err error) {
+	defer observeDB("getFinal", &err)()
 
 	tx, err := simpsql.UsingSchema(g.dbSchema)
 	if err != nil {
@@ -146,7 +290,8 @@ func (s *Service) getFinal(id int64, g *guild) (*modelFinal, error) {
 	}
 }
 
-func InsertRole(g *guild, roleID string) error {
+func InsertRole(g *guild, roleID string) (err error) {
+	defer observeDB("InsertRole", &err)()
 
 	tx, err := simpsql.UsingSchema(g.dbSchema)
 	if err != nil {
@@ -164,7 +309,8 @@ func InsertRole(g *guild, roleID string) error {
 	return nil
 }
 
-func insertChannel(g *guild, channelID string, roleID string) error {
+func insertChannel(g *guild, channelID string, roleID string) (err error) {
+	defer observeDB("insertChannel", &err)()
 
 	tx, err := simpsql.UsingSchema(g.dbSchema)
 	if err != nil {
@@ -181,7 +327,8 @@ func insertChannel(g *guild, channelID string, roleID string) error {
 	return tx.Commit()
 }
 
-func setFinalChannel(g *guild, finalID int, channelID string) error {
+func setFinalChannel(g *guild, finalID int, channelID string) (err error) {
+	defer observeDB("setFinalChannel", &err)()
 
 	tx, err := simpsql.UsingSchema(g.dbSchema)
 	if err != nil {
@@ -202,7 +349,8 @@ func setFinalChannel(g *guild, finalID int, channelID string) error {
 	return nil
 }
 
-func AddUserToFinal(g *guild, userID string, finalID int) error {
+func AddUserToFinal(g *guild, userID string, finalID int) (err error) {
+	defer observeDB("AddUserToFinal", &err)()
 
 	tx, err := simpsql.UsingSchema(g.dbSchema)
 	if err != nil {
@@ -222,7 +370,8 @@ func AddUserToFinal(g *guild, userID string, finalID int) error {
 	return nil
 }
 
-func newUser(g *guild, userID string) error {
+func newUser(g *guild, userID string) (err error) {
+	defer observeDB("newUser", &err)()
 
 	tx, err := simpsql.UsingSchema(g.dbSchema)
 	if err != nil {
@@ -242,7 +391,8 @@ func newUser(g *guild, userID string) error {
 	return nil
 }
 
-func getUser(g *guild, userID string) (*modelUser, error) {
+func getUser(g *guild, userID string) (_ *modelUser, err error) {
+	defer observeDB("getUser", &err)()
 
 	tx, err := simpsql.UsingSchema(g.dbSchema)
 	if err != nil {
@@ -278,7 +428,8 @@ func getUser(g *guild, userID string) (*modelUser, error) {
 	return mu, nil
 }
 
-func UserHasFinal(g *guild, userID string, finalID int) (bool, error) {
+func UserHasFinal(g *guild, userID string, finalID int) (_ bool, err error) {
+	defer observeDB("UserHasFinal", &err)()
 
 	tx, err := simpsql.UsingSchema(g.dbSchema)
 	if err != nil {
@@ -299,7 +450,8 @@ func UserHasFinal(g *guild, userID string, finalID int) (bool, error) {
 	return rows.Next(), nil
 }
 
-func RemoveUserFromFinal(g *guild, userID string, finalID int) error {
+func RemoveUserFromFinal(g *guild, userID string, finalID int) (err error) {
+	defer observeDB("RemoveUserFromFinal", &err)()
 
 	tx, err := simpsql.UsingSchema(g.dbSchema)
 	if err != nil {
@@ -319,7 +471,8 @@ func RemoveUserFromFinal(g *guild, userID string, finalID int) error {
 	return nil
 }
 
-func getUserFinals(g *guild, userID string) ([]modelFinal, error) {
+func getUserFinals(g *guild, userID string) (_ []modelFinal, err error) {
+	defer observeDB("getUserFinals", &err)()
 
 	tx, err := simpsql.UsingSchema(g.dbSchema)
 	if err != nil {
@@ -360,3 +513,555 @@ func getUserFinals(g *guild, userID string) ([]modelFinal, error) {
 
 	return lst, nil
 }
+
+// returns the locale a user has chosen, or "" if the user has not chosen one
+func getUserLocale(g *guild, userID string) (_ locale, err error) {
+	defer observeDB("getUserLocale", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Commit()
+
+	var lang sql.NullString
+	row := tx.QueryRow(`SELECT language FROM user WHERE iduser = ?`, userID)
+	if err = row.Scan(&lang); err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	return locale(lang.String), nil
+}
+
+func setUserLocale(g *guild, userID string, l locale) (err error) {
+	defer observeDB("setUserLocale", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO user (iduser, language)
+		VALUES (?,?)
+		ON DUPLICATE KEY UPDATE language = VALUES(language);`,
+		userID, string(l))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// returns the abbreviations and names of all majors in the guild's catalog
+func getMajors(g *guild) (_ []modelMajor, err error) {
+	defer observeDB("getMajors", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	rows, err := tx.Query(
+		`SELECT abbreviation, name FROM major
+		ORDER BY abbreviation`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lst := make([]modelMajor, 0)
+	for rows.Next() {
+		m := modelMajor{}
+		if err = rows.Scan(&m.abbr, &m.name); err == nil {
+			lst = append(lst, m)
+		}
+	}
+
+	return lst, nil
+}
+
+func saveSession(s *Service, ms modelSession) (err error) {
+	defer observeDB("saveSession", &err)()
+
+	tx, err := simpsql.UsingSchema(s.dbSchema)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`REPLACE INTO terminal_session (idchannel, iduser, idguild, kind, private, expires)
+		VALUES (?,?,?,?,?,?);`,
+		ms.chanID, ms.userID, ms.guildID, int(ms.kind), ms.private, ms.expires.UTC().Format(sqlTimeFormat))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func deleteSession(s *Service, chanID string) (err error) {
+	defer observeDB("deleteSession", &err)()
+
+	tx, err := simpsql.UsingSchema(s.dbSchema)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`DELETE FROM terminal_session
+		WHERE idchannel = ?;`,
+		chanID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// returns the saved terminal sessions on a guild
+func getSessions(s *Service, guildID string) (_ []modelSession, err error) {
+	defer observeDB("getSessions", &err)()
+
+	tx, err := simpsql.UsingSchema(s.dbSchema)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	rows, err := tx.Query(
+		`SELECT idchannel, iduser, idguild, kind, private, expires FROM terminal_session
+		WHERE idguild = ?`,
+		guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lst := make([]modelSession, 0)
+	for rows.Next() {
+		ms := modelSession{}
+		var kind int
+		var expires string
+		if err = rows.Scan(&ms.chanID, &ms.userID, &ms.guildID, &kind, &ms.private, &expires); err != nil {
+			continue
+		}
+		ms.kind = termKind(kind)
+		ms.expires, err = time.Parse(sqlTimeFormat, expires)
+		if err != nil {
+			continue
+		}
+		lst = append(lst, ms)
+	}
+
+	return lst, nil
+}
+
+func insertAuditEvent(g *guild, ev auditEvent) (err error) {
+	defer observeDB("insertAuditEvent", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO audit_event (`time`, actor, action, target, `before`, `after`) VALUES (?,?,?,?,?,?);",
+		ev.time.UTC().Format(sqlTimeFormat), ev.actor, ev.action, ev.target, nullString(ev.before), nullString(ev.after))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// returns the newest events of g's audit log that match f, newest first
+func getAuditEvents(g *guild, f auditFilter, limit int) (_ []auditEvent, err error) {
+	defer observeDB("getAuditEvents", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	where := []string{"TRUE"}
+	params := make([]interface{}, 0)
+	if f.user != "" {
+		where = append(where, "(actor = ? OR target = ?)")
+		params = append(params, f.user, userTarget(f.user))
+	}
+	if f.target != "" {
+		where = append(where, "target = ?")
+		params = append(params, f.target)
+	}
+	if f.action != "" {
+		where = append(where, "action = ?")
+		params = append(params, f.action)
+	}
+	if !f.since.IsZero() {
+		where = append(where, "`time` >= ?")
+		params = append(params, f.since.UTC().Format(sqlTimeFormat))
+	}
+	if !f.until.IsZero() {
+		where = append(where, "`time` < ?")
+		params = append(params, f.until.UTC().Format(sqlTimeFormat))
+	}
+	params = append(params, limit)
+
+	rows, err := tx.Query(
+		"SELECT idevent, `time`, actor, action, target, `before`, `after` FROM audit_event "+
+			"WHERE "+strings.Join(where, " AND ")+" ORDER BY idevent DESC LIMIT ?",
+		params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lst := make([]auditEvent, 0)
+	for rows.Next() {
+		ev := auditEvent{}
+		var evTime string
+		var before, after sql.NullString
+		if err = rows.Scan(&ev.id, &evTime, &ev.actor, &ev.action, &ev.target, &before, &after); err != nil {
+			continue
+		}
+		ev.time, err = time.Parse(sqlTimeFormat, evTime)
+		if err != nil {
+			continue
+		}
+		ev.before, ev.after = before.String, after.String
+		lst = append(lst, ev)
+	}
+
+	return lst, nil
+}
+
+// stores empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func insertErrorReport(g *guild, r errorReport) (err error) {
+	defer observeDB("insertErrorReport", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO error_report (ref, `time`, iduser, command, input, error, stack) VALUES (?,?,?,?,?,?,?);",
+		r.ref, r.time.UTC().Format(sqlTimeFormat), r.userID, r.command, r.input, r.err, r.stack)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// returns the error report with the reference ref, or nil if g has none
+func getErrorReport(g *guild, ref string) (_ *errorReport, err error) {
+	defer observeDB("getErrorReport", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	r := &errorReport{}
+	var rTime string
+	row := tx.QueryRow(
+		"SELECT ref, `time`, iduser, command, input, error, stack FROM error_report WHERE ref = ?",
+		ref)
+	err = row.Scan(&r.ref, &rTime, &r.userID, &r.command, &r.input, &r.err, &r.stack)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	r.time, err = time.Parse(sqlTimeFormat, rTime)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// returns every final with its modules and the number of users in it
+func getFinalStats(g *guild) (_ []finalStats, err error) {
+	defer observeDB("getFinalStats", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	rows, err := tx.Query(
+		`SELECT final.idfinal, final.type, module.name, module.abbr, module.fk_major, module.semester,
+			(SELECT COUNT(*) FROM ref_user_has_final AS ref WHERE ref.idfinal = final.idfinal)
+		FROM final
+		JOIN module ON final.idfinal = module.fk_idfinal
+		ORDER BY final.idfinal`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lst := make([]finalStats, 0)
+	for rows.Next() {
+		var fs finalStats
+		var major string
+		var semester int
+		if err = rows.Scan(&fs.id, &fs.typ, &fs.name, &fs.abbr, &major, &semester, &fs.members); err != nil {
+			continue
+		}
+		// a final has a row for every module it is the final of
+		if len(lst) == 0 || lst[len(lst)-1].id != fs.id {
+			lst = append(lst, fs)
+		}
+		last := &lst[len(lst)-1]
+		last.majors = append(last.majors, major)
+		last.semesters = append(last.semesters, semester)
+	}
+
+	return lst, nil
+}
+
+// returns the number of users in at least one final
+func getMemberCount(g *guild) (n int, err error) {
+	defer observeDB("getMemberCount", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Commit()
+
+	err = tx.QueryRow("SELECT COUNT(DISTINCT iduser) FROM ref_user_has_final").Scan(&n)
+	return n, err
+}
+
+// returns the joins and leaves of every day since the given time that had any, from the audit log
+func getDailyMemberships(g *guild, since time.Time) (_ []dayStats, err error) {
+	defer observeDB("getDailyMemberships", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	rows, err := tx.Query(
+		"SELECT DATE(`time`) AS day, SUM(action = ?), SUM(action = ?) FROM audit_event "+
+			"WHERE action IN (?,?) AND `time` >= ? GROUP BY day ORDER BY day",
+		auditJoin, auditLeave, auditJoin, auditLeave, since.UTC().Format(sqlTimeFormat))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lst := make([]dayStats, 0)
+	for rows.Next() {
+		var ds dayStats
+		if err = rows.Scan(&ds.day, &ds.joins, &ds.leaves); err != nil {
+			continue
+		}
+		lst = append(lst, ds)
+	}
+
+	return lst, nil
+}
+
+// returns what the channel of a final shows about it, or nil if there is no such final
+func getFinalInfo(g *guild, finalID int) (_ *finalInfo, err error) {
+	defer observeDB("getFinalInfo", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	rows, err := tx.Query(
+		`SELECT final.idfinal, final.type, final.date, final.fk_idchannel,
+			module.name, module.abbr, module.fk_major, module.semester,
+			(SELECT COUNT(*) FROM ref_user_has_final AS ref WHERE ref.idfinal = final.idfinal),
+			info.idmessage, info.topic, info.content
+		FROM final
+		JOIN module ON final.idfinal = module.fk_idfinal
+		LEFT JOIN final_info AS info ON info.idfinal = final.idfinal
+		WHERE final.idfinal = ?`,
+		finalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fi *finalInfo
+	for rows.Next() {
+		var cur finalInfo
+		var date, chanID, msgID, topic, content sql.NullString
+		var major string
+		var semester int
+		err = rows.Scan(&cur.id, &cur.typ, &date, &chanID, &cur.name, &cur.abbr, &major, &semester,
+			&cur.members, &msgID, &topic, &content)
+		if err != nil {
+			return nil, err
+		}
+
+		// a final has a row for every module it is the final of
+		if fi == nil {
+			cur.date, cur.channelID = date.String, chanID.String
+			cur.msgID, cur.topic, cur.content = msgID.String, topic.String, content.String
+			fi = &cur
+		}
+		fi.majors = append(fi.majors, major)
+		fi.semesters = append(fi.semesters, semester)
+	}
+
+	return fi, nil
+}
+
+// returns the IDs of the finals that have a channel
+func getChannelFinalIDs(g *guild) (_ []int, err error) {
+	defer observeDB("getChannelFinalIDs", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	rows, err := tx.Query("SELECT idfinal FROM final WHERE fk_idchannel IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// remembers what was written to the channel of a final
+func saveFinalInfo(g *guild, finalID int, msgID string, topic string, content string) (err error) {
+	defer observeDB("saveFinalInfo", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`REPLACE INTO final_info (idfinal, idmessage, topic, content)
+		VALUES (?,?,?,?);`,
+		finalID, msgID, topic, content)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// returns the ID of the final whose channel chanID is, or 0 if it is not the channel of a final
+func getChannelFinalID(g *guild, chanID string) (_ int, err error) {
+	defer observeDB("getChannelFinalID", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Commit()
+
+	var id int
+	row := tx.QueryRow("SELECT idfinal FROM final WHERE fk_idchannel = ?", chanID)
+	if err = row.Scan(&id); err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func insertVoiceChannel(g *guild, chanID string, finalID int, temporary bool) (err error) {
+	defer observeDB("insertVoiceChannel", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO voice_channel (idchannel, idfinal, temporary)
+		VALUES (?,?,?);`,
+		chanID, finalID, temporary)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func deleteVoiceChannel(g *guild, chanID string) (err error) {
+	defer observeDB("deleteVoiceChannel", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM voice_channel WHERE idchannel = ?;", chanID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// returns the voice channels of a final, or of every final if finalID is 0.
+// Temporary selects the voice rooms made with the voice command instead of the channels every final gets
+func getVoiceChannels(g *guild, finalID int, temporary bool) (_ []string, err error) {
+	defer observeDB("getVoiceChannels", &err)()
+
+	tx, err := simpsql.UsingSchema(g.dbSchema)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	rows, err := tx.Query(
+		`SELECT idchannel FROM voice_channel
+		WHERE (? = 0 OR idfinal = ?) AND temporary = ?`,
+		finalID, finalID, temporary)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
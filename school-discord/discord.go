@@ -22,96 +22,134 @@ func (s *Service) handleDefaultMsg(m *discordgo.MessageCreate) {
 
 }
 
-// Handles a Default message sent to a Guild
+// Handles a Default message sent as a DM
 func (s *Service) handleDefaultDirectMsg(m *discordgo.MessageCreate) {
+	if !s.handleTerminalMsg(m) { // If there is no terminal on the receiving channel
+		s.startTerminalFromDM(m)
+	}
+}
+
+// handleTerminalMsg hands m to the terminal on its channel.
+// It returns false if there is no open terminal on the channel
+func (s *Service) handleTerminalMsg(m *discordgo.MessageCreate) bool {
+
+	t, ok := s.getTerminal(m.ChannelID)
+	if !ok {
+		return false
+	}
+
+	// others that can see a private terminal's channel, like admins, must not control it
+	if m.Author.ID != t.userID {
+		return true
+	}
+
+	// terminal controls bypass the terminal's loop, so that they work while a command is running
+	switch strings.ToLower(strings.TrimSpace(m.Content)) {
+	case closeCommand:
+		t.close(tr(t.currentLang(), msgClosedByUser))
+		return true
+	case killCommand:
+		t.kill(tr(t.currentLang(), msgKilledByUser))
+		return true
+	}
+
+	return t.deliver(m)
+}
+
+// startTerminalFromDM lets users start a terminal with `edit [guild]` in their DMs, without going to a guild first
+func (s *Service) startTerminalFromDM(m *discordgo.MessageCreate) {
+
+	shared := s.sharedGuilds(m.Author.ID)
+	lang := defaultLocale
+	if len(shared) > 0 {
+		lang = shared[0].userLocale(m.Author.ID)
+	}
+
+	fields := strings.Fields(strings.ToLower(m.Content))
+	if len(fields) == 0 || fields[0] != "edit" {
+		s.ds.ChannelMessageSend(m.ChannelID, tr(lang, msgNoTerminal, s.ds.State.User.Username))
+		return
+	}
 
-	t, ok := s.terminals[m.ChannelID]
-	if ok { // If a terminal exists on the receiving channel
-		t.in <- m
-	} else { // If there is no terminal on the receiving channel
-		s.ds.ChannelMessageSend(m.ChannelID,
-			fmt.Sprintf("There is currently no active terminal on this channel. Please go to your %s administered server to start a new terminal",
-				s.ds.State.User.Username))
+	var g *guild
+	switch {
+	case len(shared) == 0:
+		s.ds.ChannelMessageSend(m.ChannelID, tr(lang, msgNoSharedGuild))
+		return
+	case len(fields) > 1:
+		g = pickGuild(shared, strings.Join(fields[1:], " "))
+		if g == nil {
+			s.ds.ChannelMessageSend(m.ChannelID, tr(lang, msgInvalidGuild, strings.Join(fields[1:], " ")))
+			return
+		}
+	case len(shared) == 1:
+		g = shared[0]
+	default:
+		list := strings.Builder{}
+		for i, g := range shared {
+			list.WriteString(fmt.Sprintf("[%2d] | %s\n", i+1, g.dgGuild.Name))
+		}
+		s.ds.ChannelMessageSend(m.ChannelID, tr(lang, msgPickGuild)+codeBlockTag+"\n"+list.String()+codeBlockTag)
+		return
+	}
+
+	err := s.newTerminal(m.Author.ID, termClass, g, "", termTimeout, msgClassGreeting)
+	if err != nil {
+		g.log.Error("Could not start terminal from DM", "user", m.Author.ID, "err", err)
 	}
 }
 
 // Handles a Default message sent to a Guild
 func (s *Service) handleDefaultGuildMsg(m *discordgo.MessageCreate) {
-	g := s.guilds[m.GuildID]
+	g, ok := s.getGuild(m.GuildID)
+	if !ok {
+		return
+	}
+
+	// private terminals run on guild channels
+	if s.handleTerminalMsg(m) {
+		return
+	}
 
 	//check for command prefix
 	if strings.HasPrefix(m.Content, g.cmdPrefix) {
-		g.cmds.Run(context.TODO(), strings.Replace(m.Content, g.cmdPrefix, "", 1), s, g, m)
+		ctx, cancel := context.WithTimeout(context.Background(), cmdTimeout)
+		defer cancel()
+		input := strings.Replace(m.Content, g.cmdPrefix, "", 1)
+		err := g.cmds.Run(ctx, input, &GuildCommandContext{serv: s, g: g, msg: m})
+		if err != nil {
+			s.handleGuildCmdErr(g, m, g.cmds.name(input), err)
+		}
 	}
 }
 
-func (s *Service) joinFinal(g *guild, final *modelFinal, userID string) (err error) {
-
-	chanName := fmt.Sprintf("%s [%d]", final.name, final.id)
+// joinFinal gives the user the role of a final, setting up its channel and role first if it has none yet.
+// Changes on discord go through the guild's job queue, notify tells the user when they are delayed
+func (s *Service) joinFinal(ctx context.Context, g *guild, final *modelFinal, userID string, notify notifyFunc) (err error) {
+	log := g.log.With("user", userID, "final", final.id)
 
 	// check to see if the final already has a channel/role
 	if final.channelID == "" {
+		log.Debug("Final has no channel yet, setting it up")
 
-		var (
-			r *discordgo.Role
-			c *discordgo.Channel
-		)
-
-		r, err = s.makeRole(g, chanName)
-		if err != nil {
-			return err
-		}
-
-		c, err = s.makeTextChan(g, chanName, g.finalsCatID, r.ID)
-		if err != nil {
-			delerr := s.ds.GuildRoleDelete(g.id(), r.ID)
-			if delerr != nil {
-				return delerr
-			}
-			return err
-		}
-
-		err = InsertRole(g, r.ID)
+		// two users joining the same final at once must not create two channels
+		err = g.jobs.do(ctx, fmt.Sprintf("final-setup:%d", final.id), notify, func(attempt int) error {
+			return s.setupFinal(g, final.id, attempt > 0)
+		})
 		if err != nil {
-			derr := s.ds.GuildRoleDelete(g.id(), r.ID)
-			if derr != nil {
-				return derr
-			}
-			_, derr = s.ds.ChannelDelete(c.ID)
-			if derr != nil {
-				return derr
-			}
+			log.Error("Could not set up final", "err", err)
 			return err
 		}
 
-		err = insertChannel(g, c.ID, r.ID)
+		mf, err := s.getFinal(int64(final.id), g)
 		if err != nil {
-			derr := s.ds.GuildRoleDelete(g.id(), r.ID)
-			if derr != nil {
-				return derr
-			}
-			_, derr = s.ds.ChannelDelete(c.ID)
-			if derr != nil {
-				return derr
-			}
 			return err
 		}
-
-		err = setFinalChannel(g, final.id, c.ID)
-		if err != nil {
-			derr := s.ds.GuildRoleDelete(g.id(), r.ID)
-			if derr != nil {
-				return derr
-			}
-			_, derr = s.ds.ChannelDelete(c.ID)
-			if derr != nil {
-				return derr
-			}
-			return err
+		if mf == nil || mf.channelID == "" {
+			return errors.New("final has no channel after setting it up")
 		}
-
-		final.roleID = r.ID
-		final.channelID = c.ID
+		final.roleID = mf.roleID
+		final.channelID = mf.channelID
 	}
 
 	usr, err := getUser(g, userID)
@@ -132,27 +170,158 @@ func (s *Service) joinFinal(g *guild, final *modelFinal, userID string) (err err
 	}
 
 	if ok {
-		return AlreadyJoinedError{errors.New("already joined")}
+		return AlreadyJoinedError{final.id}
 	}
 
-	err = s.ds.GuildMemberRoleAdd(g.id(), userID, final.roleID)
+	err = g.jobs.do(ctx, "role-add:"+userID+":"+final.roleID, notify, func(int) error {
+		return s.ds.GuildMemberRoleAdd(g.id(), userID, final.roleID)
+	})
 	if err != nil {
 		return err
 	}
 
 	err = AddUserToFinal(g, userID, final.id)
 	if err != nil {
-		derr := s.ds.GuildMemberRoleRemove(g.id(), userID, final.roleID)
-		if derr != err {
+		log.Error("Could not save membership, taking back role", "role", final.roleID, "err", err)
+		s.removeRole(g, userID, final.roleID)
+		return err
+	}
+
+	log.Info("User joined final", "role", final.roleID)
+	s.audit(g, auditEvent{actor: userID, action: auditJoin, target: finalTarget(final.id)})
+	s.refreshFinalInfo(g, final.id)
+	mMemberships.inc("join")
+	return
+}
+
+// setupFinal creates the channel and role of a final, unless it already has them.
+// On a retry, a channel or role that an earlier attempt created before failing is used instead of making another
+func (s *Service) setupFinal(g *guild, finalID int, retry bool) (err error) {
+
+	final, err := s.getFinal(int64(finalID), g)
+	if err != nil {
+		return err
+	}
+	if final == nil {
+		return errors.New("final does not exist")
+	}
+	if final.channelID != "" {
+		return nil
+	}
+
+	chanName := fmt.Sprintf("%s [%d]", final.name, final.id)
+
+	var (
+		r *discordgo.Role
+		c *discordgo.Channel
+	)
+
+	if retry {
+		r, c = s.findLeftovers(g, chanName)
+	}
+
+	if r == nil {
+		r, err = s.makeRole(g, chanName)
+		if err != nil {
 			return err
 		}
-		return err
 	}
 
+	if c == nil {
+		c, err = s.makeTextChan(g, chanName, g.finalsCatID, r.ID)
+		if err != nil {
+			delerr := s.ds.GuildRoleDelete(g.id(), r.ID)
+			if delerr != nil {
+				return delerr
+			}
+			return err
+		}
+	}
+
+	err = InsertRole(g, r.ID)
+	if err != nil {
+		return s.undoSetup(g, r.ID, c.ID, err)
+	}
+
+	err = insertChannel(g, c.ID, r.ID)
+	if err != nil {
+		return s.undoSetup(g, r.ID, c.ID, err)
+	}
+
+	err = setFinalChannel(g, final.id, c.ID)
+	if err != nil {
+		return s.undoSetup(g, r.ID, c.ID, err)
+	}
+
+	if g.voiceEnabled() {
+		// the final can be used without it, so this does not fail the setup
+		if _, err = s.setupFinalVoice(g, final.id, chanName, r.ID); err != nil {
+			g.log.Warn("Could not create voice channel of final", "final", final.id, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// deletes the role and channel of a failed setup. Returns the error that made the setup fail
+func (s *Service) undoSetup(g *guild, roleID string, chanID string, cause error) error {
+	derr := s.ds.GuildRoleDelete(g.id(), roleID)
+	if derr != nil {
+		return derr
+	}
+	_, derr = s.ds.ChannelDelete(chanID)
+	if derr != nil {
+		return derr
+	}
+	return cause
+}
+
+// finds a role and channel named name that are not known to the database, as left by a setup that failed halfway
+func (s *Service) findLeftovers(g *guild, name string) (r *discordgo.Role, c *discordgo.Channel) {
+
+	roles, err := s.ds.GuildRoles(g.id())
+	if err == nil {
+		for _, role := range roles {
+			if role.Name == name {
+				r = role
+			}
+		}
+	}
+
+	// a channel without its role is of no use
+	if r == nil {
+		return nil, nil
+	}
+
+	// discord changes the names of text channels, so the channel is found by its role instead
+	chans, err := s.ds.GuildChannels(g.id())
+	if err == nil {
+		for _, ch := range chans {
+			// the final's voice channel has the role as well
+			if ch.Type != discordgo.ChannelTypeGuildText {
+				continue
+			}
+			for _, perm := range ch.PermissionOverwrites {
+				if perm.ID == r.ID {
+					c = ch
+				}
+			}
+		}
+	}
 	return
 }
 
-func (s *Service) leaveFinal(g *guild, final *modelFinal, userID string) error {
+// takes a role away from a user, without waiting for discord to be reachable again if it is not
+func (s *Service) removeRole(g *guild, userID string, roleID string) {
+	err := g.jobs.do(context.Background(), "role-remove:"+userID+":"+roleID, nil, func(int) error {
+		return s.ds.GuildMemberRoleRemove(g.id(), userID, roleID)
+	})
+	if err != nil {
+		g.log.Error("Could not take back role", "user", userID, "role", roleID, "err", err)
+	}
+}
+
+func (s *Service) leaveFinal(ctx context.Context, g *guild, final *modelFinal, userID string, notify notifyFunc) error {
 
 	ok, err := UserHasFinal(g, userID, final.id)
 	if err != nil {
@@ -160,7 +329,7 @@ func (s *Service) leaveFinal(g *guild, final *modelFinal, userID string) error {
 	}
 
 	if !ok {
-		return NotJoinedError{errors.New("not joined")}
+		return NotJoinedError{final.id}
 	}
 
 	err = RemoveUserFromFinal(g, userID, final.id)
@@ -168,7 +337,9 @@ func (s *Service) leaveFinal(g *guild, final *modelFinal, userID string) error {
 		return err
 	}
 
-	err = s.ds.GuildMemberRoleRemove(g.id(), userID, final.roleID)
+	err = g.jobs.do(ctx, "role-remove:"+userID+":"+final.roleID, notify, func(int) error {
+		return s.ds.GuildMemberRoleRemove(g.id(), userID, final.roleID)
+	})
 	if err != nil {
 		derr := AddUserToFinal(g, userID, final.id)
 		if derr != nil {
@@ -177,6 +348,10 @@ func (s *Service) leaveFinal(g *guild, final *modelFinal, userID string) error {
 		return err
 	}
 
+	g.log.Info("User left final", "user", userID, "final", final.id)
+	s.audit(g, auditEvent{actor: userID, action: auditLeave, target: finalTarget(final.id)})
+	s.refreshFinalInfo(g, final.id)
+	mMemberships.inc("leave")
 	return nil
 }
 
@@ -198,12 +373,42 @@ func newPermViewChan(roleID string, guildID string, canView bool) (perm *discord
 }
 
 func (s *Service) makeTextChan(g *guild, name string, parentChanID string, roleID string) (*discordgo.Channel, error) {
+	return s.makeRoleChan(g, name, parentChanID, roleID, discordgo.ChannelTypeGuildText)
+}
+
+func (s *Service) makeVoiceChan(g *guild, name string, parentChanID string, roleID string) (*discordgo.Channel, error) {
+	return s.makeRoleChan(g, name, parentChanID, roleID, discordgo.ChannelTypeGuildVoice)
+}
+
+// makeRoleChan creates a channel that only members with roleID can see
+func (s *Service) makeRoleChan(g *guild, name string, parentChanID string, roleID string, typ discordgo.ChannelType) (*discordgo.Channel, error) {
 
 	perm := []*discordgo.PermissionOverwrite{
 		newPermViewChan("", g.dgGuild.ID, false),
 		newPermViewChan(roleID, g.dgGuild.ID, true),
 	}
 
+	data := discordgo.GuildChannelCreateData{
+		Name:                 name,
+		Type:                 typ,
+		PermissionOverwrites: perm,
+		ParentID:             parentChanID,
+	}
+
+	return s.ds.GuildChannelCreateComplex(g.id(), data)
+}
+
+// makePrivateChan creates a text channel that only userID and the bot can see and write in
+func (s *Service) makePrivateChan(g *guild, name string, parentChanID string, userID string) (*discordgo.Channel, error) {
+
+	const viewAndSend = 0x00000400 | 0x00000800 // view channel and send messages
+
+	perm := []*discordgo.PermissionOverwrite{
+		newPermViewChan("", g.dgGuild.ID, false),
+		{ID: userID, Type: "member", Allow: viewAndSend},
+		{ID: s.ds.State.User.ID, Type: "member", Allow: viewAndSend},
+	}
+
 	data := discordgo.GuildChannelCreateData{
 		Name:                 name,
 		Type:                 discordgo.ChannelTypeGuildText,
@@ -231,10 +436,3 @@ func (s *Service) makeRole(g *guild, name string) (*discordgo.Role, error) {
 
 	return r, nil
 }
-
-type AlreadyJoinedError struct {
-	error
-}
-type NotJoinedError struct {
-	error
-}
@@ -0,0 +1,153 @@
+package schooldiscord
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Kinds of errors that commands fail with. An error matches its kind with errors.Is,
+// so the user is told what went wrong no matter where the error came from
+var (
+	errNotFound           = errors.New("not found")
+	errConflict           = errors.New("conflict")
+	errPermission         = errors.New("permission denied")
+	errDiscordUnavailable = errors.New("discord unavailable")
+	errDBUnavailable      = errors.New("database unavailable")
+)
+
+// kindError marks an error from elsewhere, like the database driver, as being of a kind
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e kindError) Error() string {
+	return e.kind.Error() + ": " + e.err.Error()
+}
+
+func (e kindError) Unwrap() error {
+	return e.err
+}
+
+func (e kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// AlreadyJoinedError is returned when a user joins a final they are already in
+type AlreadyJoinedError struct {
+	finalID int
+}
+
+func (e AlreadyJoinedError) Error() string {
+	return fmt.Sprintf("already joined final %d", e.finalID)
+}
+
+func (e AlreadyJoinedError) Is(target error) bool {
+	return target == errConflict
+}
+
+// NotJoinedError is returned when a user leaves a final they are not in
+type NotJoinedError struct {
+	finalID int
+}
+
+func (e NotJoinedError) Error() string {
+	return fmt.Sprintf("not joined final %d", e.finalID)
+}
+
+func (e NotJoinedError) Is(target error) bool {
+	return target == errNotFound
+}
+
+// finalNotFoundError is returned when no final matches what the user asked for
+type finalNotFoundError struct {
+	query string
+}
+
+func (e finalNotFoundError) Error() string {
+	return "no final matches " + e.query
+}
+
+func (e finalNotFoundError) Is(target error) bool {
+	return target == errNotFound
+}
+
+// dbError marks err as errDBUnavailable if the database could not be reached
+func dbError(err error) error {
+	if err == nil || errors.Is(err, errDBUnavailable) {
+		return err
+	}
+
+	var nerr *net.OpError
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &nerr) ||
+		// the mysql driver's ErrInvalidConn, for connections that broke during a query
+		err.Error() == "invalid connection" {
+		return kindError{errDBUnavailable, err}
+	}
+	return err
+}
+
+// discordError marks err with its kind if it is an error of the discord API
+func discordError(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, k := range []error{errNotFound, errConflict, errPermission, errDiscordUnavailable} {
+		if errors.Is(err, k) {
+			return err
+		}
+	}
+
+	var rerr *discordgo.RESTError
+	if errors.As(err, &rerr) && rerr.Response != nil {
+		switch {
+		case rerr.Response.StatusCode == http.StatusForbidden,
+			rerr.Message != nil && rerr.Message.Code == discordgo.ErrCodeMissingPermissions:
+			return kindError{errPermission, err}
+		case rerr.Response.StatusCode == http.StatusNotFound:
+			return kindError{errNotFound, err}
+		}
+	}
+
+	if _, retry := retryAfter(err, 0); retry || errors.Is(err, errQueueStopped) {
+		return kindError{errDiscordUnavailable, err}
+	}
+	return err
+}
+
+// userMessage returns what to tell the user about an error a command failed with.
+// ok is false for errors that were not expected, which are reported instead
+func userMessage(err error) (key msgKey, args []interface{}, ok bool) {
+	var nf finalNotFoundError
+	switch {
+	case errors.As(err, &AlreadyJoinedError{}):
+		return msgAlreadyJoined, nil, true
+	case errors.As(err, &NotJoinedError{}):
+		return msgNotJoined, nil, true
+	case errors.As(err, &nf):
+		return msgFinalNotFound, []interface{}{nf.query}, true
+	case errors.Is(err, errNotFound):
+		return msgNotFound, nil, true
+	case errors.Is(err, errConflict):
+		return msgConflict, nil, true
+	case errors.Is(err, errPermission):
+		return msgPermissionDenied, nil, true
+	case errors.Is(err, errDiscordUnavailable):
+		return msgDiscordUnavailable, nil, true
+	case errors.Is(err, errDBUnavailable):
+		return msgDBUnavailable, nil, true
+	}
+	return "", nil, false
+}
+
+// isOutage reports whether err is discord or the database being unavailable.
+// These are not the fault of the command, but are logged to be noticed
+func isOutage(err error) bool {
+	return errors.Is(err, errDiscordUnavailable) || errors.Is(err, errDBUnavailable)
+}
@@ -0,0 +1,359 @@
+package schooldiscord
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	simpsql "github.com/Petrify/simp-core/sql"
+	"github.com/bwmarrin/discordgo"
+)
+
+// ----- database -----
+
+// sqlHandler answers a statement sent to the fake database. Rows are ignored for statements that are executed
+type sqlHandler func(query string, args []driver.Value) (*fakeRows, error)
+
+var fakeSQL struct {
+	mu     sync.Mutex
+	handle sqlHandler
+	stmts  []string
+}
+
+func init() {
+	sql.Register("fake", fakeDriver{})
+	db, err := sql.Open("fake", "")
+	if err != nil {
+		panic(err)
+	}
+	simpsql.DB = db
+}
+
+// onSQL answers the statements of the test with h. Statements h is nil for succeed without rows
+func onSQL(t *testing.T, h sqlHandler) {
+	fakeSQL.mu.Lock()
+	fakeSQL.handle = h
+	fakeSQL.stmts = nil
+	fakeSQL.mu.Unlock()
+
+	t.Cleanup(func() {
+		fakeSQL.mu.Lock()
+		fakeSQL.handle = nil
+		fakeSQL.mu.Unlock()
+	})
+}
+
+// sqlStatements returns the statements run since onSQL, except for selecting the schema
+func sqlStatements() []string {
+	fakeSQL.mu.Lock()
+	defer fakeSQL.mu.Unlock()
+	return append([]string(nil), fakeSQL.stmts...)
+}
+
+func answerSQL(query string, args []driver.Value) (*fakeRows, error) {
+	fakeSQL.mu.Lock()
+	h := fakeSQL.handle
+	if !strings.HasPrefix(query, "USE ") {
+		fakeSQL.stmts = append(fakeSQL.stmts, query)
+	}
+	fakeSQL.mu.Unlock()
+
+	if h == nil {
+		return &fakeRows{}, nil
+	}
+	rows, err := h(query, args)
+	if rows == nil && err == nil {
+		rows = &fakeRows{}
+	}
+	return rows, err
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{query}, nil
+}
+
+func (fakeConn) Close() error {
+	return nil
+}
+
+func (fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	query string
+}
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if _, err := answerSQL(s.query, args); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return answerSQL(s.query, args)
+}
+
+// fakeRows are the rows a statement is answered with
+type fakeRows struct {
+	cols []string
+	vals [][]driver.Value
+}
+
+// rows returns rows with the given columns. Strings are returned as bytes, like the mysql driver does
+func rows(cols []string, vals ...[]interface{}) *fakeRows {
+	r := &fakeRows{cols: cols}
+	for _, row := range vals {
+		dv := make([]driver.Value, len(row))
+		for i, v := range row {
+			if s, ok := v.(string); ok {
+				v = []byte(s)
+			}
+			dv[i] = v
+		}
+		r.vals = append(r.vals, dv)
+	}
+	return r
+}
+
+func (r *fakeRows) Columns() []string {
+	if r.cols == nil && len(r.vals) > 0 {
+		return make([]string, len(r.vals[0]))
+	}
+	return r.cols
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.vals) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.vals[0])
+	r.vals = r.vals[1:]
+	return nil
+}
+
+// ----- discord -----
+
+// fakeDiscord answers the REST requests of a session and records the messages it sends
+type fakeDiscord struct {
+	mu      sync.Mutex
+	sent    []string
+	respond func(req *http.Request) (status int, body string) // optional, for requests that should fail
+}
+
+func (d *fakeDiscord) RoundTrip(req *http.Request) (*http.Response, error) {
+	status, body := http.StatusOK, `{"id":"1"}`
+	if d.respond != nil {
+		if st, b := d.respond(req); st != 0 {
+			status, body = st, b
+		}
+	}
+
+	if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/messages") && status == http.StatusOK {
+		var msg struct {
+			Content string `json:"content"`
+		}
+		if req.Body != nil {
+			json.NewDecoder(req.Body).Decode(&msg)
+		}
+		d.mu.Lock()
+		d.sent = append(d.sent, msg.Content)
+		d.mu.Unlock()
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// messages returns the content of every message sent so far
+func (d *fakeDiscord) messages() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.sent...)
+}
+
+// sentContaining reports whether a message containing text was sent
+func (d *fakeDiscord) sentContaining(text string) bool {
+	for _, m := range d.messages() {
+		if strings.Contains(m, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// ----- clock -----
+
+// fakeClock only moves when it is advanced
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	armed  chan time.Duration // receives the duration of every timer that is reset
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC), armed: make(chan time.Duration, 100)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) clockTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1), when: c.now.Add(d), active: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// advance moves the clock forward by d, firing the timers that are due
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		if t.active && !t.when.After(c.now) {
+			t.active = false
+			select {
+			case t.c <- c.now:
+			default:
+			}
+		}
+	}
+}
+
+// waitArmed waits until a timer is reset to d
+func (c *fakeClock) waitArmed(t *testing.T, d time.Duration) {
+	t.Helper()
+	for {
+		select {
+		case got := <-c.armed:
+			if got == d {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no timer was reset to %s", d)
+		}
+	}
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	c      chan time.Time
+	when   time.Time
+	active bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	was := t.active
+	t.active = false
+	return was
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	was := t.active
+	t.active = true
+	t.when = t.clock.now.Add(d)
+	t.clock.mu.Unlock()
+
+	t.clock.armed <- d
+	return was
+}
+
+// ----- service -----
+
+// newTestService returns a service talking to a fake discord, with a single guild
+func newTestService(t *testing.T, clk clock) (*Service, *guild, *fakeDiscord) {
+	t.Helper()
+
+	fd := &fakeDiscord{}
+	ds, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds.Client = &http.Client{Transport: fd}
+	ds.MaxRestRetries = 0
+
+	lg := newLogger(log.New(ioutil.Discard, "", 0))
+	s := &Service{
+		ds:        ds,
+		dbSchema:  "test",
+		guilds:    make(map[string]*guild),
+		terminals: make(map[string]*terminal),
+		shared:    make(map[string]sharedGuildsEntry),
+		log:       lg,
+		clock:     clk,
+	}
+
+	g := &guild{
+		cmds:        guildCommands,
+		index:       newSearchIndex(),
+		jobs:        newJobQueue(lg),
+		log:         lg,
+		infoPending: make(map[int]bool),
+		voiceRooms:  make(map[string]*time.Timer),
+		cmdPrefix:   "!",
+		lang:        localeDE,
+		termMode:    termModeDM,
+		dgGuild:     &discordgo.Guild{ID: "100", Name: "Testserver"},
+		ds:          ds,
+		dbSchema:    "test",
+	}
+	t.Cleanup(g.jobs.stop)
+	s.guilds[g.id()] = g
+
+	return s, g, fd
+}
+
+// userMsg is a message of user on channel
+func userMsg(userID string, chanID string, content string) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{
+		ChannelID: chanID,
+		Content:   content,
+		Author:    &discordgo.User{ID: userID},
+	}}
+}
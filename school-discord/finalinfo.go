@@ -0,0 +1,125 @@
+package schooldiscord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// how long after a final changed its info is updated, so that a burst of joins leads to a single edit
+const finalInfoDelay = 5 * time.Second
+
+// finalInfo is what the topic and pinned info message of a final's channel show
+type finalInfo struct {
+	finalStats
+	date      string // "" while the date is not known
+	channelID string
+
+	// as last written to discord
+	msgID   string
+	topic   string
+	content string
+}
+
+// the channel topic leaves out the member count, as discord only allows changing it twice in ten minutes
+func (fi *finalInfo) renderTopic(lang locale) string {
+	return tr(lang, msgFinalTopic, fi.abbr, fi.typ, fi.dateOr(lang), fi.modules())
+}
+
+func (fi *finalInfo) renderMessage(lang locale) string {
+	return tr(lang, msgFinalInfo, fi.name, fi.abbr, fi.typ, fi.dateOr(lang), fi.modules(), fi.members)
+}
+
+func (fi *finalInfo) dateOr(lang locale) string {
+	if fi.date == "" {
+		return tr(lang, msgFinalDateUnknown)
+	}
+	return fi.date
+}
+
+// refreshFinalInfo updates the topic and info message of a final's channel after finalInfoDelay
+func (s *Service) refreshFinalInfo(g *guild, finalID int) {
+	g.infoMu.Lock()
+	defer g.infoMu.Unlock()
+	if g.infoPending[finalID] {
+		return
+	}
+	g.infoPending[finalID] = true
+
+	time.AfterFunc(finalInfoDelay, func() {
+		// changes from now on need another update
+		g.infoMu.Lock()
+		delete(g.infoPending, finalID)
+		g.infoMu.Unlock()
+
+		if !s.beginWork() {
+			return
+		}
+		defer s.endWork()
+
+		err := g.jobs.do(context.Background(), fmt.Sprintf("final-info:%d", finalID), nil, func(int) error {
+			return s.writeFinalInfo(g, finalID)
+		})
+		if err != nil {
+			g.log.Warn("Could not update final info", "final", finalID, "err", err)
+		}
+	})
+}
+
+// refreshAllFinalInfo updates the info of every final that has a channel, as after the catalog was changed.
+// Finals whose info did not change are left alone
+func (s *Service) refreshAllFinalInfo(g *guild) {
+	ids, err := getChannelFinalIDs(g)
+	if err != nil {
+		g.log.Warn("Could not list finals to update their info", "err", err)
+		return
+	}
+	for _, id := range ids {
+		s.refreshFinalInfo(g, id)
+	}
+}
+
+// writeFinalInfo sets the topic of a final's channel and posts, or edits, its pinned info message
+func (s *Service) writeFinalInfo(g *guild, finalID int) error {
+	fi, err := getFinalInfo(g, finalID)
+	if err != nil || fi == nil || fi.channelID == "" {
+		return err
+	}
+
+	topic := fi.renderTopic(g.language())
+	if topic != fi.topic {
+		// ChannelEditComplex would also move the channel to the top, as it always sends a position
+		_, err = s.ds.RequestWithBucketID("PATCH", discordgo.EndpointChannel(fi.channelID),
+			map[string]string{"topic": topic}, discordgo.EndpointChannel(fi.channelID))
+		if err != nil {
+			return err
+		}
+	}
+
+	content := fi.renderMessage(g.language())
+	msgID := fi.msgID
+	if msgID != "" && content != fi.content {
+		_, err = s.ds.ChannelMessageEdit(fi.channelID, msgID, content)
+		if errors.Is(discordError(err), errNotFound) {
+			msgID = "" // somebody deleted it, post a new one
+		} else if err != nil {
+			return err
+		}
+	}
+	if msgID == "" {
+		m, err := s.ds.ChannelMessageSend(fi.channelID, content)
+		if err != nil {
+			return err
+		}
+		msgID = m.ID
+		if err = s.ds.ChannelMessagePin(fi.channelID, msgID); err != nil {
+			// the message is still of use without being pinned
+			g.log.Warn("Could not pin final info", "final", finalID, "channel", fi.channelID, "err", err)
+		}
+	}
+
+	return saveFinalInfo(g, finalID, msgID, topic, content)
+}
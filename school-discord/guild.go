@@ -3,19 +3,36 @@ package schooldiscord
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
-	"github.com/Petrify/simp-core/commands"
-	"github.com/Petrify/simp-core/service"
 	simpsql "github.com/Petrify/simp-core/sql"
 	"github.com/bwmarrin/discordgo"
 )
 
 type guild struct {
-	cmds *commands.Interpreter
+	cmds  *commandSet
+	index *searchIndex
+	jobs  *jobQueue //changes to the guild on discord
+	log   *logger
 
-	//settings
+	//finals whose channel info is about to be updated
+	infoPending map[int]bool
+	infoMu      sync.Mutex
+
+	//temporary voice rooms, with the timer that deletes them while they are empty
+	voiceRooms map[string]*time.Timer
+	voiceMu    sync.Mutex
+
+	//settings. Those admins can change are guarded by settingsMu, as commands read them at the same time
+	settingsMu  sync.RWMutex
 	cmdPrefix   string
 	finalsCatID string
+	lang        locale
+	termMode    termMode
+	auditChanID string //where audit events are mirrored to, if set
+	finalVoice  bool   //whether finals get a voice channel along with their text channel
 
 	dgGuild *discordgo.Guild
 
@@ -25,13 +42,31 @@ type guild struct {
 
 func (s *Service) newGuild(dgGuild *discordgo.Guild) error {
 
-	s.Log.Println("Attempting to load guild:", dgGuild.Name, dgGuild.ID)
+	log := s.log.With("guild", dgGuild.ID)
+
+	// the guild is loaded again after reconnecting. Open terminals and running commands point to it,
+	// so it is kept with its settings and job queue, and only what may have changed while offline is refreshed
+	if g, ok := s.getGuild(dgGuild.ID); ok {
+		log.Info("Guild reconnected", "name", dgGuild.Name)
+		if err := g.index.load(s, g); err != nil {
+			log.Warn("Could not rebuild search index, retrying on first search", "err", err)
+			g.index.invalidate()
+		}
+		s.refreshAllFinalInfo(g)
+		return nil
+	}
+
+	log.Info("Loading guild", "name", dgGuild.Name)
 
 	g := guild{
-		cmds:     interpreterGuild(),
-		dgGuild:  dgGuild,
-		ds:       s.ds,
-		dbSchema: fmt.Sprintf("%s_guild%s", service.Schema(s), dgGuild.ID),
+		cmds:        guildCommands,
+		log:         log,
+		infoPending: make(map[int]bool),
+		voiceRooms:  make(map[string]*time.Timer),
+		index:       newSearchIndex(),
+		dgGuild:     dgGuild,
+		ds:          s.ds,
+		dbSchema:    fmt.Sprintf("%s_guild%s", s.dbSchema, dgGuild.ID),
 	}
 
 	// Verify Database Schema
@@ -39,7 +74,7 @@ func (s *Service) newGuild(dgGuild *discordgo.Guild) error {
 	if err != nil {
 		return err
 	} else if !ok {
-		s.Log.Print("Guild has no database schema. Attempting fisrt time setup")
+		log.Info("Guild has no database schema, setting it up", "schema", g.dbSchema)
 		if err = simpsql.MakeSchema(g.dbSchema); err != nil {
 			return err
 		}
@@ -52,7 +87,7 @@ func (s *Service) newGuild(dgGuild *discordgo.Guild) error {
 
 		sc, _ := simpsql.Open("sd_guild_schema.sql")
 		for sc.Next() {
-			s.Log.Print(sc.Stmt())
+			log.Debug("Guild schema statement", "stmt", sc.Stmt())
 		}
 
 		if err = simpsql.ExecScript(tx, "sd_guild_schema.sql"); err != nil {
@@ -63,15 +98,29 @@ func (s *Service) newGuild(dgGuild *discordgo.Guild) error {
 		tx.Commit()
 	}
 
-	err = g.initCommands(s)
-	if err != nil {
+	// tables added after a guild's first time setup
+	if err = upgradeSchema(&g); err != nil {
 		return err
 	}
 
 	s.loadSettings(&g)
 
+	if err = g.index.load(s, &g); err != nil {
+		log.Warn("Could not build search index, retrying on first search", "err", err)
+	}
+
+	g.jobs = newJobQueue(log)
+
+	s.guildMu.Lock()
 	s.guilds[dgGuild.ID] = &g
-	s.Log.Println("Guild connected:", g.dgGuild.Name)
+	s.guildMu.Unlock()
+	log.Info("Guild connected", "name", g.dgGuild.Name)
+
+	s.restoreTerminals(&g)
+
+	// the catalog may have changed while the bot was offline
+	s.refreshAllFinalInfo(&g)
+	s.restoreVoiceRooms(&g)
 	return nil
 }
 
@@ -79,54 +128,181 @@ func (g *guild) id() string {
 	return g.dgGuild.ID
 }
 
-func (g *guild) initCommands(s *Service) error {
+// language returns the default language of the guild
+func (g *guild) language() locale {
+	g.settingsMu.RLock()
+	defer g.settingsMu.RUnlock()
+	return g.lang
+}
 
-	g.cmds.AddCommand("terminal admin", adminTerminal)
-	g.cmds.AddCommand("edit", classTerminal)
+func (g *guild) setLanguage(l locale) {
+	g.settingsMu.Lock()
+	g.lang = l
+	g.settingsMu.Unlock()
+}
 
-	return nil
+// terminalMode returns where the guild's terminals are opened
+func (g *guild) terminalMode() termMode {
+	g.settingsMu.RLock()
+	defer g.settingsMu.RUnlock()
+	return g.termMode
 }
 
-// -----COMMAND FUNCTIONS--------
+func (g *guild) setTerminalMode(m termMode) {
+	g.settingsMu.Lock()
+	g.termMode = m
+	g.settingsMu.Unlock()
+}
+
+// voiceEnabled returns whether finals get a voice channel along with their text channel
+func (g *guild) voiceEnabled() bool {
+	g.settingsMu.RLock()
+	defer g.settingsMu.RUnlock()
+	return g.finalVoice
+}
 
-func verifyGuild(ext []interface{}) (*Service, *guild, *discordgo.MessageCreate) {
-	e0 := ext[0].(*Service)
-	e1 := ext[1].(*guild)
-	e2 := ext[2].(*discordgo.MessageCreate)
-	return e0, e1, e2
+func (g *guild) setVoiceEnabled(on bool) {
+	g.settingsMu.Lock()
+	g.finalVoice = on
+	g.settingsMu.Unlock()
 }
 
-func adminTerminal(ctx context.Context, args []string, ext ...interface{}) error {
+// auditChannel returns the channel audit events are mirrored to, or "" if there is none
+func (g *guild) auditChannel() string {
+	g.settingsMu.RLock()
+	defer g.settingsMu.RUnlock()
+	return g.auditChanID
+}
+
+func (g *guild) setAuditChannel(chanID string) {
+	g.settingsMu.Lock()
+	g.auditChanID = chanID
+	g.settingsMu.Unlock()
+}
 
-	s, g, msg := verifyGuild(ext)
+func (s *Service) getGuild(guildID string) (*guild, bool) {
+	s.guildMu.RLock()
+	defer s.guildMu.RUnlock()
 
-	//TODO: My ID hardcoded as Amdin (bad)
-	if msg.Author.ID == "84787975480700928" {
-		return s.newTerminal(msg.Author.ID, adminCommands(), g, termTimeout, "Started an Admin Terminal")
+	g, ok := s.guilds[guildID]
+	return g, ok
+}
+
+// how long the guilds a user shares with the bot are remembered.
+// Finding them out asks discord about every guild the user is not cached on
+const sharedGuildsTTL = 5 * time.Minute
+
+type sharedGuildsEntry struct {
+	guildIDs []string
+	expires  time.Time
+}
+
+// returns all loaded guilds that userID is a member of, sorted by name
+func (s *Service) sharedGuilds(userID string) []*guild {
+	now := s.clock.Now()
+
+	s.sharedMu.Lock()
+	e, ok := s.shared[userID]
+	s.sharedMu.Unlock()
+	if ok && now.Before(e.expires) {
+		shared := make([]*guild, 0, len(e.guildIDs))
+		for _, id := range e.guildIDs {
+			if g, ok := s.getGuild(id); ok {
+				shared = append(shared, g)
+			}
+		}
+		return shared
 	}
-	s.ds.ChannelMessageSend(msg.ChannelID, "Access Denied")
-	return nil
+
+	s.guildMu.RLock()
+	lst := make([]*guild, 0, len(s.guilds))
+	for _, g := range s.guilds {
+		lst = append(lst, g)
+	}
+	s.guildMu.RUnlock()
+
+	shared := make([]*guild, 0, len(lst))
+	for _, g := range lst {
+		if g.isMember(userID) {
+			shared = append(shared, g)
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool { return shared[i].dgGuild.Name < shared[j].dgGuild.Name })
+
+	e = sharedGuildsEntry{guildIDs: make([]string, len(shared)), expires: now.Add(sharedGuildsTTL)}
+	for i, g := range shared {
+		e.guildIDs[i] = g.id()
+	}
+	s.sharedMu.Lock()
+	// expired entries are dropped here, so that users who are gone do not pile up
+	for id, old := range s.shared {
+		if !now.Before(old.expires) {
+			delete(s.shared, id)
+		}
+	}
+	s.shared[userID] = e
+	s.sharedMu.Unlock()
+
+	return shared
+}
+
+func (g *guild) isMember(userID string) bool {
+	if _, err := g.ds.State.Member(g.id(), userID); err == nil {
+		return true
+	}
+	// members are only cached once they have been seen, so ask discord
+	_, err := g.ds.GuildMember(g.id(), userID)
+	return err == nil
+}
+
+// returns the name the user goes by on the guild, or their ID if it can not be found
+func (g *guild) memberName(userID string) string {
+	m, err := g.ds.State.Member(g.id(), userID)
+	if err != nil {
+		if m, err = g.ds.GuildMember(g.id(), userID); err != nil {
+			return userID
+		}
+	}
+	if m.Nick != "" {
+		return m.Nick
+	}
+	return m.User.Username
 }
 
-func classTerminal(ctx context.Context, args []string, ext ...interface{}) error {
+// returns the locale to talk to a user in. A user's own choice overrides the guild default
+func (g *guild) userLocale(userID string) locale {
+	l, err := getUserLocale(g, userID)
+	if err != nil {
+		g.log.Warn("Could not look up language of user, using the guild's", "user", userID, "err", err)
+		return g.language()
+	}
+	if l == "" {
+		return g.language()
+	}
+	return l
+}
+
+// -----COMMAND FUNCTIONS--------
 
-	s, g, msg := verifyGuild(ext)
+func adminTerminal(ctx context.Context, args []string, c *GuildCommandContext) error {
 
-	return s.newTerminal(msg.Author.ID, classEditCommands(), g, termTimeout,
-		"Hallo! Ich kann dir helfen deine Prüfungen zu konfigurieren! Ganz einfach diese Commands (ohne !) eingeben.\n"+
-			"`search <begriff>` um nach Prüfungen zu suchen\n"+
-			"`join <ID>` um der Prüfung beizutreten\n"+
-			"`leave <ID>` um eine Prüfung zu verlassen\n"+
-			"`list` um eine liste deiner Prüfungen zu sehen")
+	//TODO: My ID hardcoded as Amdin (bad)
+	if c.Author().ID == "84787975480700928" {
+		return c.serv.newTerminal(c.Author().ID, termAdmin, c.g, c.msg.ChannelID, termTimeout, msgAdminGreeting)
+	}
+	c.ReplyMsg(msgAccessDenied)
+	return nil
 }
 
-func cmdTest(ctx context.Context, args []string, ext ...interface{}) error {
+func classTerminal(ctx context.Context, args []string, c *GuildCommandContext) error {
+	return c.serv.newTerminal(c.Author().ID, termClass, c.g, c.msg.ChannelID, termTimeout, msgClassGreeting)
+}
 
-	s, _, msg := verifyGuild(ext)
+func cmdTest(ctx context.Context, args []string, c *GuildCommandContext) error {
 
-	_, err := s.ds.ChannelMessageSend(msg.ChannelID, "Pong!")
+	err := c.Reply("Pong!")
 	if err != nil {
-		s.Log.Print("Error sending message: ", err)
+		c.log().Warn("Could not send message", "err", err)
 	}
 
 	return nil
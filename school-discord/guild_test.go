@@ -0,0 +1,37 @@
+package schooldiscord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestReconnectKeepsGuildOfOpenTerminals(t *testing.T) {
+	onSQL(t, nil)
+	clk := newFakeClock()
+	s, g, _ := newTestService(t, clk)
+	term := startTestTerminal(t, s, g, clk, time.Minute)
+	defer term.close("test over")
+
+	if err := s.newGuild(&discordgo.Guild{ID: g.id(), Name: "Testserver"}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, ok := s.getGuild(g.id())
+	if !ok || loaded != g {
+		t.Fatal("reconnecting replaced the guild open terminals point to")
+	}
+	if term.currentGuild() != loaded {
+		t.Error("terminal does not point to the loaded guild")
+	}
+
+	// settings changed from the terminal reach the guild everybody else reads
+	g.setLanguage(localeEN)
+	if loaded.userLocale("2") != localeEN {
+		t.Error("language set on the terminal's guild is not used by the loaded guild")
+	}
+	if !g.index.loaded {
+		t.Error("search index was not rebuilt after reconnecting")
+	}
+}
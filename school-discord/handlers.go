@@ -5,13 +5,19 @@ import "github.com/bwmarrin/discordgo"
 func (s *Service) registerHandlers() {
 	s.ds.AddHandler(s.defGuildCreate())
 	s.ds.AddHandler(s.defMessageCreate())
+	s.ds.AddHandler(s.defVoiceStateUpdate())
 }
 
 func (s *Service) defGuildCreate() func(ds *discordgo.Session, m *discordgo.GuildCreate) {
 	return func(ds *discordgo.Session, m *discordgo.GuildCreate) {
+		if !s.beginWork() {
+			return
+		}
+		defer s.endWork()
+
 		err := s.newGuild(m.Guild)
 		if err != nil {
-			s.Log.Print("Error while loading guild: ", err)
+			s.log.Error("Could not load guild", "guild", m.Guild.ID, "err", err)
 		}
 	}
 }
@@ -22,6 +28,25 @@ func (s *Service) defMessageCreate() func(ds *discordgo.Session, m *discordgo.Me
 		if m.Author.Bot {
 			return
 		}
+		if !s.beginWork() {
+			return
+		}
+		defer s.endWork()
+
 		s.handleDefaultMsg(m)
 	}
 }
+
+func (s *Service) defVoiceStateUpdate() func(ds *discordgo.Session, m *discordgo.VoiceStateUpdate) {
+	return func(ds *discordgo.Session, m *discordgo.VoiceStateUpdate) {
+		if !s.beginWork() {
+			return
+		}
+		defer s.endWork()
+
+		// the state already has the update, so the rooms can be checked for who is left in them
+		if g, ok := s.getGuild(m.GuildID); ok {
+			s.checkVoiceRooms(g)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package schooldiscord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// cmdInfo describes a registered command for help and usage messages
+type cmdInfo struct {
+	path     string
+	desc     msgKey
+	usage    string // arguments of the command, shown after its path
+	examples []string
+
+	run func(ctx context.Context, args []string, cc cmdContext) error
+}
+
+// call runs the command with args. A panic of the command is returned as a panicError
+func (ci *cmdInfo) call(ctx context.Context, args []string, cc cmdContext) (err error) {
+	defer recoverCmd(&err)
+	return ci.run(ctx, args, cc)
+}
+
+// the full usage line of the command, prefixed with the interpreter's command prefix
+func (ci *cmdInfo) usageLine(prefix string) string {
+	if ci.usage == "" {
+		return prefix + ci.path
+	}
+	return prefix + ci.path + " " + ci.usage
+}
+
+// errUsage is returned by commands that were called with arguments they do not support.
+// It is answered with the usage of the command
+var errUsage = errors.New("invalid arguments")
+
+// returned by commandSet.Run for input that does not start with a registered command
+type unknownCommandError struct {
+	cmd string
+}
+
+func (e unknownCommandError) Error() string {
+	return "unknown command " + e.cmd
+}
+
+// returned by commandSet.Run when a command answered errUsage or an argError
+type usageError struct {
+	info  *cmdInfo
+	cause *argError // the argument that was wrong, if the command could tell
+}
+
+func (e usageError) Error() string {
+	return "invalid arguments for " + e.info.path
+}
+
+// the message answering the error, in lang
+func (e usageError) message(lang locale, prefix string) string {
+	if e.cause != nil {
+		return e.cause.message(lang) + "\n" + tr(lang, msgHelpUsage, e.info.usageLine(prefix))
+	}
+	return tr(lang, msgInvalidArgs, e.info.usageLine(prefix), prefix, e.info.path)
+}
+
+// commandSet is an interpreter that knows the description and usage of its commands.
+// All commands of a set are run in the same scope, and every set has a help command
+type commandSet struct {
+	scope cmdScope
+	infos []*cmdInfo // in the order they were added
+}
+
+func newCommandSet(scope cmdScope) *commandSet {
+	c := &commandSet{scope: scope}
+	if scope == scopeGuild {
+		c.add("help", cmdGuildHelp, msgHelpHelp, "[command]", "help", "help edit")
+	} else {
+		c.add("help", cmdHelp, msgHelpHelp, "[command]", "help", "help join")
+	}
+	return c
+}
+
+// add registers f under path. f has to be a guildCmdFunc or termCmdFunc matching the scope of the set.
+// Command sets are built when the bot starts, so a command registered wrong panics right away
+func (c *commandSet) add(path string, f interface{}, desc msgKey, usage string, examples ...string) {
+
+	for _, ci := range c.infos {
+		if ci.path == path {
+			panic("command " + path + " registered twice")
+		}
+	}
+
+	var run func(ctx context.Context, args []string, cc cmdContext) error
+	switch f := f.(type) {
+	case guildCmdFunc:
+		if c.scope != scopeGuild {
+			panic("guild command " + path + " registered outside of a guild")
+		}
+		run = func(ctx context.Context, args []string, cc cmdContext) error {
+			return f(ctx, args, cc.(*GuildCommandContext))
+		}
+	case termCmdFunc:
+		if c.scope != scopeTerminal {
+			panic("terminal command " + path + " registered outside of a terminal")
+		}
+		run = func(ctx context.Context, args []string, cc cmdContext) error {
+			return f(ctx, args, cc.(*TerminalCommandContext))
+		}
+	default:
+		panic(fmt.Sprintf("command %s has an unsupported handler type %T", path, f))
+	}
+
+	c.infos = append(c.infos, &cmdInfo{path: path, desc: desc, usage: usage, examples: examples, run: run})
+}
+
+// lookup returns the command that words start with and the number of words in its path,
+// or nil if there is none. Commands are case insensitive, and the longest matching path wins
+func (c *commandSet) lookup(words []string) (*cmdInfo, int) {
+	var best *cmdInfo
+	n := 0
+	for _, ci := range c.infos {
+		path := strings.Split(ci.path, " ")
+		if len(path) > len(words) {
+			continue
+		}
+		match := true
+		for i := range path {
+			if !strings.EqualFold(path[i], words[i]) {
+				match = false
+				break
+			}
+		}
+		if match && len(path) > n {
+			best, n = ci, len(path)
+		}
+	}
+	return best, n
+}
+
+// name returns the path of the command input is for, or "" if it is for none
+func (c *commandSet) name(input string) string {
+	words, err := splitArgs(input)
+	if err != nil {
+		return ""
+	}
+	if ci, _ := c.lookup(words); ci != nil {
+		return ci.path
+	}
+	return ""
+}
+
+// Run runs the command input starts with, with the rest of input split up by splitArgs.
+// cc has to be a *GuildCommandContext or *TerminalCommandContext, matching the scope of the set.
+// The error of the command is returned as is, or as a usageError if it was errUsage or an argError
+func (c *commandSet) Run(ctx context.Context, input string, cc cmdContext) error {
+
+	switch cc.(type) {
+	case *GuildCommandContext:
+		if c.scope != scopeGuild {
+			return errors.New("guild context given to a terminal's commands")
+		}
+	case *TerminalCommandContext:
+		if c.scope != scopeTerminal {
+			return errors.New("terminal context given to a guild's commands")
+		}
+	}
+
+	words, err := splitArgs(input)
+	if err != nil {
+		return err
+	}
+
+	ci, n := c.lookup(words)
+	if ci == nil {
+		mCommands.inc("unknown", "unknown")
+		return unknownCommandError{input}
+	}
+
+	err = ci.call(ctx, words[n:], cc)
+
+	var ae argError
+	switch {
+	case err == nil:
+		mCommands.inc(ci.path, "ok")
+	case err == errUsage:
+		mCommands.inc(ci.path, "usage")
+		return usageError{info: ci}
+	case errors.As(err, &ae):
+		mCommands.inc(ci.path, "usage")
+		return usageError{info: ci, cause: &ae}
+	default:
+		if _, _, ok := userMessage(err); ok {
+			mCommands.inc(ci.path, "rejected")
+		} else {
+			mCommands.inc(ci.path, "error")
+		}
+	}
+	return err
+}
+
+// lists the commands of the set, or describes a single command
+func (c *commandSet) help(lang locale, prefix string, args []string) string {
+
+	if len(args) > 0 {
+		path := strings.TrimPrefix(strings.Join(args, " "), prefix)
+		for _, ci := range c.infos {
+			if ci.path == path {
+				return c.describe(ci, lang, prefix)
+			}
+		}
+
+		// commands of several words may be asked for by their first words
+		matches := c.withPrefix(path + " ")
+		if len(matches) == 0 {
+			return tr(lang, msgHelpUnknown, path, prefix)
+		}
+		return c.list(matches, lang, prefix)
+	}
+
+	return c.list(c.infos, lang, prefix)
+}
+
+func (c *commandSet) withPrefix(p string) []*cmdInfo {
+	out := make([]*cmdInfo, 0)
+	for _, ci := range c.infos {
+		if strings.HasPrefix(ci.path, p) {
+			out = append(out, ci)
+		}
+	}
+	return out
+}
+
+func (c *commandSet) list(infos []*cmdInfo, lang locale, prefix string) string {
+	sorted := make([]*cmdInfo, len(infos))
+	copy(sorted, infos)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].path < sorted[j].path })
+
+	width := 0
+	for _, ci := range sorted {
+		width = max(width, len(prefix+ci.path))
+	}
+
+	b := strings.Builder{}
+	b.WriteString(tr(lang, msgHelpList) + "\n")
+	b.WriteString(codeBlockTag + "\n")
+	for _, ci := range sorted {
+		b.WriteString(fmt.Sprintf("%-*s | %s\n", width, prefix+ci.path, tr(lang, ci.desc)))
+	}
+	b.WriteString(codeBlockTag + "\n")
+	b.WriteString(tr(lang, msgHelpFooter, prefix))
+	return b.String()
+}
+
+func (c *commandSet) describe(ci *cmdInfo, lang locale, prefix string) string {
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("**%s%s** - %s\n", prefix, ci.path, tr(lang, ci.desc)))
+	b.WriteString(tr(lang, msgHelpUsage, ci.usageLine(prefix)))
+	if len(ci.examples) > 0 {
+		b.WriteString("\n" + tr(lang, msgHelpExamples))
+		for _, e := range ci.examples {
+			b.WriteString("\n`" + prefix + e + "`")
+		}
+	}
+	return b.String()
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func cmdHelp(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	return c.Reply(c.term.cmds.help(c.Lang(), "", args))
+}
+
+func cmdGuildHelp(ctx context.Context, args []string, c *GuildCommandContext) error {
+	return c.Reply(c.g.cmds.help(c.Lang(), c.g.cmdPrefix, args))
+}
+
+// answers input that was rejected by a guild's commands
+func (s *Service) handleGuildCmdErr(g *guild, m *discordgo.MessageCreate, cmd string, err error) {
+	switch e := err.(type) {
+	case unknownCommandError, argError:
+		// other bots may share the command prefix, so input that is not clearly for us is ignored
+	case usageError:
+		s.ds.ChannelMessageSend(m.ChannelID, e.message(g.userLocale(m.Author.ID), g.cmdPrefix))
+	default:
+		log := g.log.With("user", m.Author.ID, "channel", m.ChannelID)
+		lang := g.userLocale(m.Author.ID)
+		if key, args, ok := userMessage(err); ok {
+			if isOutage(err) {
+				log.Warn("Guild command failed, a service is unavailable", "command", cmd, "err", err)
+			}
+			s.ds.ChannelMessageSend(m.ChannelID, tr(lang, key, args...))
+			return
+		}
+		ref := s.reportError(g, log, m.Author.ID, cmd, m.Content, err)
+		s.ds.ChannelMessageSend(m.ChannelID, tr(lang, msgExecutionError, ref))
+	}
+}
@@ -0,0 +1,59 @@
+package schooldiscord
+
+import "sync"
+
+// searchIndex caches a guild's module catalog in memory so that searches do not have to query the database.
+// It is loaded lazily and rebuilt on the next search after being invalidated.
+// The bot never changes the fields that are searched, like names and abbreviations. Admins who change
+// the catalog in the database directly apply their changes with the reindex command
+type searchIndex struct {
+	mu     sync.RWMutex
+	loaded bool
+	list   moduleList
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{}
+}
+
+// invalidate marks the index as stale. Call this whenever the catalog of a guild changes
+func (idx *searchIndex) invalidate() {
+	idx.mu.Lock()
+	idx.loaded = false
+	idx.list = moduleList{}
+	idx.mu.Unlock()
+}
+
+// load (re)builds the index from the guild's database
+func (idx *searchIndex) load(s *Service, g *guild) error {
+	finals, err := s.getModuleCatalog(g)
+	if err != nil {
+		return err
+	}
+
+	lst := newModuleList(finals)
+
+	idx.mu.Lock()
+	idx.list = lst
+	idx.loaded = true
+	idx.mu.Unlock()
+	return nil
+}
+
+// catalog returns the guild's finals matching f, loading the search index if needed
+func (s *Service) catalog(g *guild, f searchFilter) (moduleList, error) {
+	g.index.mu.RLock()
+	loaded, lst := g.index.loaded, g.index.list
+	g.index.mu.RUnlock()
+
+	if !loaded {
+		if err := g.index.load(s, g); err != nil {
+			return moduleList{}, err
+		}
+		g.index.mu.RLock()
+		lst = g.index.list
+		g.index.mu.RUnlock()
+	}
+
+	return lst.filter(f), nil
+}
@@ -1,17 +1,40 @@
 package schooldiscord
 
-import "github.com/Petrify/simp-core/commands"
+// Command sets are shared by every guild and terminal. They are built once when the bot starts
+var (
+	guildCommands     = interpreterGuild()
+	adminTermCommands = adminCommands()
+	classTermCommands = classEditCommands()
+)
 
-func interpreterGuild() (I *commands.Interpreter) {
-	I = commands.NewInterpreter()
-	I.AddCommand("terminal admin", adminTerminal)
-	I.AddCommand("edit", classTerminal)
-	I.AddCommand("ping", cmdTest)
+func interpreterGuild() (I *commandSet) {
+	I = newCommandSet(scopeGuild)
+	I.add("terminal admin", adminTerminal, msgHelpAdminTerminal, "")
+	I.add("edit", classTerminal, msgHelpEdit, "")
+	I.add("ping", cmdTest, msgHelpPing, "")
+	I.add("voice", cmdVoice, msgHelpVoice, "")
 	return
 }
 
-func adminCommands() (I *commands.Interpreter) {
-	I = commands.NewInterpreter()
+func adminCommands() (I *commandSet) {
+	I = newCommandSet(scopeTerminal)
+	I.add("language", cmdDefaultLanguage, msgHelpDefaultLanguage, "[de|en]", "language en")
+	I.add("reindex", cmdReindex, msgHelpReindex, "")
+	I.add("terminals", cmdTerminals, msgHelpTerminals, "")
+	I.add("kill", cmdKillTerminal, msgHelpKill, "<user ID|channel ID>", "kill 123456789012345678")
+	I.add("terminal mode", cmdTerminalMode, msgHelpTermMode, "[auto|dm|channel]", "terminal mode channel")
+	I.add("audit channel", cmdAuditChannel, msgHelpAuditChannel, "[channel|off]", "audit channel #log", "audit channel off")
+	I.add("audit log", cmdAudit, msgHelpAudit, "[user:<ID>] [final:<ID>] [action:<action>] [since:<date>] [until:<date>]",
+		"audit log", "audit log user:123456789012345678", "audit log final:42 since:2021-03-01", "audit log action:setting")
+	I.add("voice", cmdFinalVoice, msgHelpFinalVoice, "[on|off]", "voice on")
+	I.add("error", cmdErrorLookup, msgHelpError, "<reference>", "error 3F9A1C02")
+	I.add("stats summary", cmdStatsSummary, msgHelpStatsSummary, "[--top <n>]", "stats summary", "stats summary --top 20")
+	I.add("stats finals", cmdStatsFinals, msgHelpStatsFinals, "")
+	I.add("stats majors", cmdStatsMajors, msgHelpStatsMajors, "")
+	I.add("stats semesters", cmdStatsSemesters, msgHelpStatsSemesters, "")
+	I.add("stats empty", cmdStatsEmpty, msgHelpStatsEmpty, "")
+	I.add("stats growth", cmdStatsGrowth, msgHelpStatsGrowth, "[--days <n>]", "stats growth", "stats growth --days 7")
+	I.add("stats report", cmdStatsReport, msgHelpStatsReport, "[csv|md] [--days <n>]", "stats report", "stats report csv --days 90")
 	// I.AddCommand("db add", dbAdd)
 	// I.AddCommand("db del", dbDel)
 	// I.AddCommand("db rename", dbRename)
@@ -21,11 +44,19 @@ func adminCommands() (I *commands.Interpreter) {
 	return
 }
 
-func classEditCommands() (I *commands.Interpreter) {
-	I = commands.NewInterpreter()
-	I.AddCommand("search", cmdSearch)
-	I.AddCommand("join", cmdJoin)
-	I.AddCommand("leave", cmdLeave)
-	I.AddCommand("list", cmdList)
+func classEditCommands() (I *commandSet) {
+	I = newCommandSet(scopeTerminal)
+	I.add("search", cmdSearch, msgHelpSearch, "<term> [filters] [--all]",
+		"search mathe", "search ma1", "search major:INF sem:2", "search prog --all", `search "lineare algebra" --sem 2`)
+	I.add("browse", cmdBrowse, msgHelpBrowse, "[major] [semester]", "browse", "browse INF 3")
+	I.add("join", cmdJoin, msgHelpJoin, "<ID|abbreviation|name> | <ID> <ID>... | all <filters>",
+		"join 42", "join MA1", `join "Mathematik 1"`, "join 42 43 44", "join all major:INF sem:3")
+	I.add("leave", cmdLeave, msgHelpLeave, "<ID|abbreviation|name> | <ID> <ID>... | all [filters]",
+		"leave 42", "leave all", "leave all sem:1")
+	I.add("list", cmdList, msgHelpListFinals, "")
+	I.add("next", cmdNextPage, msgHelpNext, "")
+	I.add("prev", cmdPrevPage, msgHelpPrev, "")
+	I.add("language", cmdLanguage, msgHelpLanguage, "[de|en]", "language en")
+	I.add("guild", cmdGuild, msgHelpGuild, "[number|name]", "guild", "guild 2")
 	return
 }
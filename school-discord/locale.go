@@ -0,0 +1,532 @@
+package schooldiscord
+
+import (
+	"fmt"
+	"strings"
+)
+
+type locale string
+
+const (
+	localeDE locale = "de"
+	localeEN locale = "en"
+
+	defaultLocale = localeDE
+)
+
+// all locales the bot can speak, in the order they are presented to users
+var locales = []locale{localeDE, localeEN}
+
+type msgKey string
+
+const (
+	msgNoTerminal          msgKey = "no_terminal"
+	msgTerminalExists      msgKey = "terminal_exists"
+	msgTerminalClosed      msgKey = "terminal_closed"
+	msgSessionExpired      msgKey = "session_expired"
+	msgUnknownCommand      msgKey = "unknown_command"
+	msgInvalidArgs         msgKey = "invalid_args"
+	msgExecutionError      msgKey = "execution_error"
+	msgAccessDenied        msgKey = "access_denied"
+	msgAdminGreeting       msgKey = "admin_greeting"
+	msgClassGreeting       msgKey = "class_greeting"
+	msgNoResults           msgKey = "no_results"
+	msgSearchResults       msgKey = "search_results"
+	msgSearchTableHeader   msgKey = "search_table_header"
+	msgFinalNotFound       msgKey = "final_not_found"
+	msgAlreadyJoined       msgKey = "already_joined"
+	msgJoined              msgKey = "joined"
+	msgNotJoined           msgKey = "not_joined"
+	msgNotFound            msgKey = "not_found"
+	msgConflict            msgKey = "conflict"
+	msgPermissionDenied    msgKey = "permission_denied"
+	msgDiscordUnavailable  msgKey = "discord_unavailable"
+	msgDBUnavailable       msgKey = "db_unavailable"
+	msgLeft                msgKey = "left"
+	msgNoFinals            msgKey = "no_finals"
+	msgYourFinals          msgKey = "your_finals"
+	msgLanguageCurrent     msgKey = "language_current"
+	msgLanguageSet         msgKey = "language_set"
+	msgLanguageDefaultSet  msgKey = "language_default_set"
+	msgLanguageUnknown     msgKey = "language_unknown"
+	msgPageFooter          msgKey = "page_footer"
+	msgNoMorePages         msgKey = "no_more_pages"
+	msgBrowseMajors        msgKey = "browse_majors"
+	msgBrowseTableHeader   msgKey = "browse_table_header"
+	msgReindexed           msgKey = "reindexed"
+	msgCancelled           msgKey = "cancelled"
+	msgBulkConfirmJoin     msgKey = "bulk_confirm_join"
+	msgBulkConfirmLeave    msgKey = "bulk_confirm_leave"
+	msgBulkAllUsage        msgKey = "bulk_all_usage"
+	msgBulkSummary         msgKey = "bulk_summary"
+	msgBulkJoined          msgKey = "bulk_joined"
+	msgBulkLeft            msgKey = "bulk_left"
+	msgBulkAlreadyJoined   msgKey = "bulk_already_joined"
+	msgBulkNotJoined       msgKey = "bulk_not_joined"
+	msgBulkNotFound        msgKey = "bulk_not_found"
+	msgBulkNotAnID         msgKey = "bulk_not_an_id"
+	msgBulkFailed          msgKey = "bulk_failed"
+	msgAmbiguousFinal      msgKey = "ambiguous_final"
+	msgClosedByUser        msgKey = "closed_by_user"
+	msgKilledByUser        msgKey = "killed_by_user"
+	msgKilledByAdmin       msgKey = "killed_by_admin"
+	msgNoTerminals         msgKey = "no_terminals"
+	msgTerminalList        msgKey = "terminal_list"
+	msgTerminalTableHeader msgKey = "terminal_table_header"
+	msgNoSuchTerminal      msgKey = "no_such_terminal"
+	msgTerminalsKilled     msgKey = "terminals_killed"
+	msgGuildSwitched       msgKey = "guild_switched"
+	msgGuildList           msgKey = "guild_list"
+	msgInvalidGuild        msgKey = "invalid_guild"
+	msgNoSharedGuild       msgKey = "no_shared_guild"
+	msgPickGuild           msgKey = "pick_guild"
+	msgSessionRestored     msgKey = "session_restored"
+	msgTermModeCurrent     msgKey = "term_mode_current"
+	msgTermModeSet         msgKey = "term_mode_set"
+	msgAuditList           msgKey = "audit_list"
+	msgAuditTableHeader    msgKey = "audit_table_header"
+	msgAuditEmpty          msgKey = "audit_empty"
+	msgAuditChannelCurrent msgKey = "audit_channel_current"
+	msgAuditChannelNone    msgKey = "audit_channel_none"
+	msgAuditChannelSet     msgKey = "audit_channel_set"
+	msgAuditChannelOff     msgKey = "audit_channel_off"
+	msgAuditChannelInvalid msgKey = "audit_channel_invalid"
+	msgErrorNotFound       msgKey = "error_not_found"
+	msgErrorReport         msgKey = "error_report"
+	msgFinalTopic          msgKey = "final_topic"
+	msgFinalInfo           msgKey = "final_info"
+	msgFinalDateUnknown    msgKey = "final_date_unknown"
+	msgTermModeUnknown     msgKey = "term_mode_unknown"
+	msgDMsClosed           msgKey = "dms_closed"
+	msgTerminalOpened      msgKey = "terminal_opened"
+	msgPrivateTerminal     msgKey = "private_terminal"
+	msgHelpList            msgKey = "help_list"
+	msgHelpFooter          msgKey = "help_footer"
+	msgHelpUsage           msgKey = "help_usage"
+	msgHelpExamples        msgKey = "help_examples"
+	msgHelpUnknown         msgKey = "help_unknown"
+	msgHelpHelp            msgKey = "help_help"
+	msgHelpSearch          msgKey = "help_search"
+	msgHelpBrowse          msgKey = "help_browse"
+	msgHelpJoin            msgKey = "help_join"
+	msgHelpLeave           msgKey = "help_leave"
+	msgHelpListFinals      msgKey = "help_list_finals"
+	msgHelpNext            msgKey = "help_next"
+	msgHelpPrev            msgKey = "help_prev"
+	msgHelpLanguage        msgKey = "help_language"
+	msgHelpGuild           msgKey = "help_guild"
+	msgHelpDefaultLanguage msgKey = "help_default_language"
+	msgHelpReindex         msgKey = "help_reindex"
+	msgHelpTerminals       msgKey = "help_terminals"
+	msgHelpKill            msgKey = "help_kill"
+	msgHelpTermMode        msgKey = "help_term_mode"
+	msgHelpAudit           msgKey = "help_audit"
+	msgHelpAuditChannel    msgKey = "help_audit_channel"
+	msgHelpError           msgKey = "help_error"
+	msgHelpAdminTerminal   msgKey = "help_admin_terminal"
+	msgHelpEdit            msgKey = "help_edit"
+	msgHelpPing            msgKey = "help_ping"
+	msgArgUnclosedQuote    msgKey = "arg_unclosed_quote"
+	msgArgUnknownFlag      msgKey = "arg_unknown_flag"
+	msgArgMissingValue     msgKey = "arg_missing_value"
+	msgArgNotANumber       msgKey = "arg_not_a_number"
+	msgArgNotAnID          msgKey = "arg_not_an_id"
+	msgArgNotADate         msgKey = "arg_not_a_date"
+	msgBotStopping         msgKey = "bot_stopping"
+	msgShuttingDown        msgKey = "shutting_down"
+	msgJobDelayed          msgKey = "job_delayed"
+	msgJobRetrying         msgKey = "job_retrying"
+
+	// statistics
+	msgStatsSummary         msgKey = "stats_summary"
+	msgStatsTop             msgKey = "stats_top"
+	msgStatsFinalsList      msgKey = "stats_finals_list"
+	msgStatsFinalsHeader    msgKey = "stats_finals_header"
+	msgStatsMajorsList      msgKey = "stats_majors_list"
+	msgStatsMajorsHeader    msgKey = "stats_majors_header"
+	msgStatsSemestersList   msgKey = "stats_semesters_list"
+	msgStatsSemestersHeader msgKey = "stats_semesters_header"
+	msgStatsEmptyList       msgKey = "stats_empty_list"
+	msgStatsNoEmpty         msgKey = "stats_no_empty"
+	msgStatsGrowthList      msgKey = "stats_growth_list"
+	msgStatsGrowthHeader    msgKey = "stats_growth_header"
+	msgStatsNoGrowth        msgKey = "stats_no_growth"
+	msgStatsUnknownFormat   msgKey = "stats_unknown_format"
+	msgStatsReportSent      msgKey = "stats_report_sent"
+	msgStatsReportTitle     msgKey = "stats_report_title"
+	msgStatsReportFinalCols msgKey = "stats_report_final_cols"
+	msgStatsReportGroupCols msgKey = "stats_report_group_cols"
+	msgStatsReportDayCols   msgKey = "stats_report_day_cols"
+	msgHelpStatsSummary     msgKey = "help_stats_summary"
+	msgHelpStatsFinals      msgKey = "help_stats_finals"
+	msgHelpStatsMajors      msgKey = "help_stats_majors"
+	msgHelpStatsSemesters   msgKey = "help_stats_semesters"
+	msgHelpStatsEmpty       msgKey = "help_stats_empty"
+	msgHelpStatsGrowth      msgKey = "help_stats_growth"
+	msgHelpStatsReport      msgKey = "help_stats_report"
+
+	// voice channels
+	msgVoiceNotFinalChannel msgKey = "voice_not_final_channel"
+	msgVoiceRoomLimit       msgKey = "voice_room_limit"
+	msgVoiceRoomCreated     msgKey = "voice_room_created"
+	msgVoiceRoomName        msgKey = "voice_room_name"
+	msgFinalVoiceCurrentOn  msgKey = "final_voice_current_on"
+	msgFinalVoiceCurrentOff msgKey = "final_voice_current_off"
+	msgFinalVoiceOn         msgKey = "final_voice_on"
+	msgFinalVoiceOff        msgKey = "final_voice_off"
+	msgFinalVoiceUnknown    msgKey = "final_voice_unknown"
+	msgHelpVoice            msgKey = "help_voice"
+	msgHelpFinalVoice       msgKey = "help_final_voice"
+)
+
+// catalog holds every user facing text of the bot, mapped by locale and message key.
+// Texts are format strings and are filled in by tr.
+var catalog = map[locale]map[msgKey]string{
+	localeDE: {
+		msgNoTerminal:     "Auf diesem Kanal ist gerade kein Terminal aktiv. Schreibe `edit` oder gehe auf deinen von %s verwalteten Server um ein neues Terminal zu starten",
+		msgTerminalExists: "Auf diesem Kanal ist bereits ein Terminal aktiv. Bitte benutze %s um dieses Terminal zu schließen bevor du ein neues öffnest. Falls das Terminal hängt, benutze %s (nicht empfohlen)",
+		msgTerminalClosed: "Das Terminal ist jetzt geschlossen.\nGrund: %s\n",
+		msgSessionExpired: "Die Sitzung ist abgelaufen",
+		msgUnknownCommand: "Unbekannter Befehl. `help` zeigt alle Befehle",
+		msgInvalidArgs:    "Dieser Befehl unterstützt diese Argumente nicht. Benutzung: `%s`\nMehr dazu mit `%shelp %s`",
+		msgExecutionError: "Oh nein! Beim Ausführen deines Befehls ist ein Fehler aufgetreten!\nFalls das Problem weiterhin besteht, melde bitte den Fehler mit der Referenz **%s**",
+		msgAccessDenied:   "Zugriff verweigert",
+		msgAdminGreeting:  "Admin-Terminal gestartet. `help` zeigt alle Befehle",
+		msgClassGreeting: "Hallo! Ich kann dir helfen deine Prüfungen zu konfigurieren! Ganz einfach Befehle (ohne !) eingeben, `help` zeigt alle Befehle.\n" +
+			"`!close` um das Terminal zu schließen",
+		msgNoResults:           "Keine Ergebnisse für **%s**",
+		msgSearchResults:       "Suchergebnisse für **%s**\n",
+		msgSearchTableHeader:   "[-ID-] | Prüfungsfach (Studiengänge)\n",
+		msgFinalNotFound:       "%s wurde nicht gefunden",
+		msgAlreadyJoined:       "Du bist dieser Prüfung bereits beigetreten",
+		msgJoined:              "**%s** wurde erfolgreich zu Deinen Prüfungen hinzugefügt.",
+		msgNotJoined:           "Man kann keine Prüfung verlassen, der man nie beigetreten ist!",
+		msgNotFound:            "Das gesuchte Objekt wurde nicht gefunden",
+		msgConflict:            "Das wurde gleichzeitig schon geändert, versuch es bitte noch einmal",
+		msgPermissionDenied:    "Dem Bot fehlen auf diesem Server die Rechte dafür. Bitte wende dich an einen Admin",
+		msgDiscordUnavailable:  "Discord ist gerade nicht erreichbar, versuch es bitte später noch einmal",
+		msgDBUnavailable:       "Die Datenbank ist gerade nicht erreichbar, versuch es bitte später noch einmal",
+		msgLeft:                "**%s** wurde erfolgreich von Deinen Prüfungen gelöscht.",
+		msgNoFinals:            "Du hast noch keine Prüfungen",
+		msgYourFinals:          "Deine Prüfungen:",
+		msgLanguageCurrent:     "Aktuelle Sprache: **%s**. Verfügbar: %s",
+		msgLanguageSet:         "Sprache auf **%s** gesetzt",
+		msgLanguageDefaultSet:  "Standardsprache des Servers auf **%s** gesetzt",
+		msgLanguageUnknown:     "Unbekannte Sprache **%s**. Verfügbar: %s",
+		msgPageFooter:          "Seite %d/%d – `next`/`prev` zum Blättern",
+		msgNoMorePages:         "Keine weiteren Seiten",
+		msgBrowseMajors:        "Studiengänge:",
+		msgBrowseTableHeader:   "[-ID-] | Sem | Typ | Prüfungsfach\n",
+		msgReindexed:           "Suchindex wurde neu aufgebaut",
+		msgCancelled:           "Abgebrochen",
+		msgBulkConfirmJoin:     "Folgenden %d Prüfungen beitreten? (ja/nein)\n",
+		msgBulkConfirmLeave:    "Folgende %d Prüfungen verlassen? (ja/nein)\n",
+		msgBulkAllUsage:        "Benutzung: `join all <Filter>` (z.B. `join all major:INF sem:3`) oder `leave all [Filter]`",
+		msgBulkSummary:         "Ergebnis:",
+		msgBulkJoined:          "beigetreten",
+		msgBulkLeft:            "verlassen",
+		msgBulkAlreadyJoined:   "bereits beigetreten",
+		msgBulkNotJoined:       "nicht beigetreten",
+		msgBulkNotFound:        "nicht gefunden",
+		msgBulkNotAnID:         "keine ID",
+		msgBulkFailed:          "fehlgeschlagen",
+		msgAmbiguousFinal:      "Mehrere Prüfungen passen zu **%s**, bitte wähle eine per ID:\n",
+		msgClosedByUser:        "Vom Benutzer geschlossen",
+		msgKilledByUser:        "Vom Benutzer zwangsweise beendet",
+		msgKilledByAdmin:       "Von einem Administrator beendet",
+		msgNoTerminals:         "Es sind keine Terminals geöffnet",
+		msgTerminalList:        "Offene Terminals:",
+		msgTerminalTableHeader: "Benutzer             | Kanal                | Offen    | Server\n",
+		msgNoSuchTerminal:      "Kein Terminal für **%s** gefunden",
+		msgTerminalsKilled:     "%d Terminal(s) beendet",
+		msgGuildSwitched:       "Das Terminal arbeitet jetzt auf **%s**",
+		msgGuildList:           "Deine Server (* = aktuell), wechseln mit `guild <Nr>`:",
+		msgInvalidGuild:        "**%s** ist keiner deiner Server",
+		msgNoSharedGuild:       "Du bist auf keinem Server, den ich verwalte",
+		msgPickGuild:           "Für welchen Server? Schreibe `edit <Nr>`:\n",
+		msgSessionRestored:     "Der Bot wurde neu gestartet, deine Sitzung läuft weiter.",
+		msgTermModeCurrent:     "Terminal-Modus des Servers: **%s** (verfügbar: %s)",
+		msgTermModeSet:         "Terminal-Modus des Servers auf **%s** gesetzt",
+		msgAuditList:           "Audit-Log:",
+		msgAuditTableHeader:    "Zeit             | Benutzer             | Aktion   | Ziel                   | Änderung\n",
+		msgAuditEmpty:          "Keine Einträge im Audit-Log gefunden",
+		msgAuditChannelCurrent: "Das Audit-Log wird in %s gespiegelt. Mit `audit channel off` wird das abgeschaltet",
+		msgAuditChannelNone:    "Das Audit-Log wird in keinen Kanal gespiegelt. Mit `audit channel <Kanal>` wird ein Kanal festgelegt",
+		msgAuditChannelSet:     "Das Audit-Log wird jetzt in %s gespiegelt",
+		msgAuditChannelOff:     "Das Audit-Log wird nicht mehr gespiegelt",
+		msgAuditChannelInvalid: "**%s** ist kein Textkanal dieses Servers",
+		msgErrorNotFound:       "Kein Fehler mit der Referenz **%s** auf diesem Server gefunden",
+		msgErrorReport:         "Fehler **%s** vom %s\nBenutzer: %s\nBefehl: %s\nEingabe: `%s`",
+		msgFinalTopic:          "%s | Prüfungsart: %s | Datum: %s | Module: %s",
+		msgFinalInfo:           "**%s** (%s)\nPrüfungsart: %s\nDatum: %s\nModule: %s\nMitglieder: **%d**",
+		msgFinalDateUnknown:    "noch nicht bekannt",
+		msgTermModeUnknown:     "Unbekannter Terminal-Modus \"%s\". Verfügbar: %s",
+		msgDMsClosed:           "%s, ich kann dir keine Direktnachrichten schicken. Bitte erlaube Direktnachrichten von Servermitgliedern und versuche es erneut.",
+		msgTerminalOpened:      "%s, dein Terminal wartet in %s auf dich.",
+		msgPrivateTerminal:     "Dieser Kanal ist nur für dich und wird gelöscht, sobald das Terminal geschlossen wird.",
+		msgHelpList:            "Verfügbare Befehle:",
+		msgHelpFooter:          "Mehr zu einem Befehl mit `%shelp <Befehl>`",
+		msgHelpUsage:           "Benutzung: `%s`",
+		msgHelpExamples:        "Beispiele:",
+		msgHelpUnknown:         "Es gibt keinen Befehl **%s**. `%shelp` zeigt alle Befehle",
+		msgHelpHelp:            "Zeigt alle Befehle oder die Hilfe zu einem Befehl",
+		msgHelpSearch:          "Sucht nach Prüfungen über Name, Kürzel oder ID. Filter: `major:<Studiengang>` `sem:<Semester>` `type:<Prüfungsart>`, `--all` zeigt alle Treffer",
+		msgHelpBrowse:          "Zeigt alle Studiengänge oder die Prüfungen eines Studiengangs",
+		msgHelpJoin:            "Tritt Prüfungen bei, über ID, Kürzel oder Name. `all` mit Filtern tritt allen gefilterten bei",
+		msgHelpLeave:           "Verlässt Prüfungen, über ID, Kürzel oder Name. `all` verlässt alle, optional gefiltert",
+		msgHelpListFinals:      "Zeigt deine Prüfungen",
+		msgHelpNext:            "Zeigt die nächste Seite einer langen Liste",
+		msgHelpPrev:            "Zeigt die vorherige Seite einer langen Liste",
+		msgHelpLanguage:        "Zeigt oder ändert deine Sprache",
+		msgHelpGuild:           "Zeigt deine Server oder wechselt zu einem anderen",
+		msgHelpDefaultLanguage: "Zeigt oder ändert die Standardsprache des Servers",
+		msgHelpReindex:         "Baut den Suchindex des Servers neu auf",
+		msgHelpTerminals:       "Zeigt alle offenen Terminals",
+		msgHelpKill:            "Beendet die Terminals eines Nutzers oder Kanals",
+		msgHelpTermMode:        "Zeigt oder ändert, wo Terminals geöffnet werden",
+		msgHelpAudit:           "Zeigt das Audit-Log, gefiltert nach Benutzer, Prüfung, Aktion oder Datum",
+		msgHelpAuditChannel:    "Zeigt oder ändert den Kanal, in den das Audit-Log gespiegelt wird",
+		msgHelpError:           "Zeigt einen Fehler, den ein Benutzer mit seiner Referenz gemeldet hat",
+		msgHelpAdminTerminal:   "Öffnet ein Admin-Terminal",
+		msgHelpEdit:            "Öffnet ein Terminal, um deine Prüfungen zu verwalten",
+		msgHelpPing:            "Prüft, ob der Bot antwortet",
+		msgArgUnclosedQuote:    "In **%s** fehlt ein schließendes Anführungszeichen",
+		msgArgUnknownFlag:      "**%s** ist keine gültige Option für diesen Befehl",
+		msgArgMissingValue:     "Für **%s** fehlt ein Wert",
+		msgArgNotANumber:       "**%s** ist keine gültige Zahl",
+		msgArgNotAnID:          "**%s** ist keine gültige ID",
+		msgArgNotADate:         "**%s** ist kein gültiges Datum, erwartet wird JJJJ-MM-TT",
+		msgBotStopping:         "Der Bot wird neu gestartet, deine Sitzung geht danach weiter",
+		msgShuttingDown:        "Der Bot wird gerade neu gestartet. Bitte versuche es gleich noch einmal.",
+		msgJobDelayed:          "Discord lässt mich gerade nur langsam arbeiten, deine Anfrage wird noch bearbeitet...",
+		msgJobRetrying:         "Discord ist gerade nicht erreichbar, ich versuche es in %s noch einmal...",
+
+		msgStatsSummary:         "**%d** Benutzer sind in **%d** Prüfungen eingetragen, zusammen **%d** Anmeldungen. **%d** von %d Prüfungen haben keine Mitglieder",
+		msgStatsTop:             "Die %d Prüfungen mit den meisten Mitgliedern:",
+		msgStatsFinalsList:      "Mitglieder je Prüfung:",
+		msgStatsFinalsHeader:    "  ID   | Mitglieder | Prüfung\n",
+		msgStatsMajorsList:      "Anmeldungen je Studiengang:",
+		msgStatsMajorsHeader:    "Studiengang  | Prüfungen | Anmeldungen\n",
+		msgStatsSemestersList:   "Anmeldungen je Semester:",
+		msgStatsSemestersHeader: "Semester     | Prüfungen | Anmeldungen\n",
+		msgStatsEmptyList:       "Prüfungen ohne Mitglieder:",
+		msgStatsNoEmpty:         "Jede Prüfung hat mindestens ein Mitglied",
+		msgStatsGrowthList:      "Beitritte und Austritte der letzten %d Tage:",
+		msgStatsGrowthHeader:    "Tag        | Beitritte | Austritte | Anmeldungen\n",
+		msgStatsNoGrowth:        "In den letzten %d Tagen ist niemand einer Prüfung beigetreten oder ausgetreten",
+		msgStatsUnknownFormat:   "Unbekanntes Format **%s**, verfügbar sind csv und md",
+		msgStatsReportSent:      "Bericht über die Anmeldungen als %s:",
+		msgStatsReportTitle:     "Bericht über die Anmeldungen",
+		msgStatsReportFinalCols: "ID,Prüfung,Module,Mitglieder",
+		msgStatsReportGroupCols: "Gruppe,Prüfungen,Anmeldungen",
+		msgStatsReportDayCols:   "Tag,Beitritte,Austritte,Anmeldungen",
+		msgHelpStatsSummary:     "Zeigt eine Übersicht der Anmeldungen und die Prüfungen mit den meisten Mitgliedern",
+		msgHelpStatsFinals:      "Zeigt die Mitglieder jeder Prüfung",
+		msgHelpStatsMajors:      "Zeigt die Anmeldungen je Studiengang",
+		msgHelpStatsSemesters:   "Zeigt die Anmeldungen je Semester eines Studiengangs",
+		msgHelpStatsEmpty:       "Zeigt die Prüfungen ohne Mitglieder",
+		msgHelpStatsGrowth:      "Zeigt die Beitritte und Austritte je Tag, aus dem Audit-Log",
+		msgHelpStatsReport:      "Erstellt einen Bericht mit allen Statistiken als CSV oder Markdown",
+
+		msgVoiceNotFinalChannel: "Sprachräume können nur im Kanal einer Prüfung erstellt werden",
+		msgVoiceRoomLimit:       "Diese Prüfung hat schon %d Sprachräume. Ein neuer kann erstellt werden, sobald einer davon leer ist",
+		msgVoiceRoomCreated:     "Sprachraum %s erstellt. Er wird gelöscht, wenn er %d Minuten lang leer ist",
+		msgVoiceRoomName:        "%s Lernraum %d",
+		msgFinalVoiceCurrentOn:  "Jede Prüfung bekommt einen Sprachkanal. Mit `voice off` wird das abgeschaltet",
+		msgFinalVoiceCurrentOff: "Prüfungen bekommen keinen eigenen Sprachkanal. Mit `voice on` wird das eingeschaltet",
+		msgFinalVoiceOn:         "Jede Prüfung bekommt jetzt einen Sprachkanal, %d wurden erstellt",
+		msgFinalVoiceOff:        "Prüfungen bekommen keinen Sprachkanal mehr, %d wurden gelöscht",
+		msgFinalVoiceUnknown:    "Unbekannte Einstellung \"%s\". Verfügbar: on, off",
+		msgHelpVoice:            "Erstellt im Kanal einer Prüfung einen Sprachraum, der gelöscht wird, wenn er leer bleibt",
+		msgHelpFinalVoice:       "Zeigt oder ändert, ob jede Prüfung einen Sprachkanal bekommt",
+	},
+	localeEN: {
+		msgNoTerminal:     "There is currently no active terminal on this channel. Write `edit` or go to your %s administered server to start a new terminal",
+		msgTerminalExists: "There is already an active terminal on this channel. Please use %s to close this terminal before opening a new one. If the terminal is stuck, use %s (not recommended)",
+		msgTerminalClosed: "Terminal is now closed.\nReason: %s\n",
+		msgSessionExpired: "The session has expired",
+		msgUnknownCommand: "Unknown command. `help` lists all commands",
+		msgInvalidArgs:    "That command does not support those arguments. Usage: `%s`\nMore with `%shelp %s`",
+		msgExecutionError: "Uh Oh! An error occurred while executing your command!\nIf this issue persists please file an error report with the reference **%s**",
+		msgAccessDenied:   "Access Denied",
+		msgAdminGreeting:  "Started an Admin Terminal. `help` lists all commands",
+		msgClassGreeting: "Hello! I can help you configure your finals! Just enter commands (without !), `help` lists all of them.\n" +
+			"`!close` to close the terminal",
+		msgNoResults:           "No results for **%s**",
+		msgSearchResults:       "Search results for **%s**\n",
+		msgSearchTableHeader:   "[-ID-] | Final (Majors)\n",
+		msgFinalNotFound:       "%s was not found",
+		msgAlreadyJoined:       "You have already joined this final",
+		msgJoined:              "**%s** was successfully added to your finals.",
+		msgNotJoined:           "You can not leave a final you never joined!",
+		msgNotFound:            "What you asked for could not be found",
+		msgConflict:            "This was changed at the same time, please try again",
+		msgPermissionDenied:    "The bot lacks the permissions for this on this server. Please ask an admin",
+		msgDiscordUnavailable:  "Discord can not be reached right now, please try again later",
+		msgDBUnavailable:       "The database can not be reached right now, please try again later",
+		msgLeft:                "**%s** was successfully removed from your finals.",
+		msgNoFinals:            "You have not joined any finals yet",
+		msgYourFinals:          "Your finals:",
+		msgLanguageCurrent:     "Current language: **%s**. Available: %s",
+		msgLanguageSet:         "Language set to **%s**",
+		msgLanguageDefaultSet:  "Default server language set to **%s**",
+		msgLanguageUnknown:     "Unknown language **%s**. Available: %s",
+		msgPageFooter:          "Page %d/%d – `next`/`prev` to turn pages",
+		msgNoMorePages:         "No more pages",
+		msgBrowseMajors:        "Majors:",
+		msgBrowseTableHeader:   "[-ID-] | Sem | Typ | Final\n",
+		msgReindexed:           "Search index has been rebuilt",
+		msgCancelled:           "Cancelled",
+		msgBulkConfirmJoin:     "Join the following %d finals? (yes/no)\n",
+		msgBulkConfirmLeave:    "Leave the following %d finals? (yes/no)\n",
+		msgBulkAllUsage:        "Usage: `join all <filters>` (e.g. `join all major:INF sem:3`) or `leave all [filters]`",
+		msgBulkSummary:         "Results:",
+		msgBulkJoined:          "joined",
+		msgBulkLeft:            "left",
+		msgBulkAlreadyJoined:   "already joined",
+		msgBulkNotJoined:       "not joined",
+		msgBulkNotFound:        "not found",
+		msgBulkNotAnID:         "not an ID",
+		msgBulkFailed:          "failed",
+		msgAmbiguousFinal:      "Several finals match **%s**, please choose one by its ID:\n",
+		msgClosedByUser:        "Closed by the user",
+		msgKilledByUser:        "Killed by the user",
+		msgKilledByAdmin:       "Killed by an administrator",
+		msgNoTerminals:         "There are no open terminals",
+		msgTerminalList:        "Open terminals:",
+		msgTerminalTableHeader: "User                 | Channel              | Open for | Server\n",
+		msgNoSuchTerminal:      "No terminal found for **%s**",
+		msgTerminalsKilled:     "Killed %d terminal(s)",
+		msgGuildSwitched:       "The terminal now works on **%s**",
+		msgGuildList:           "Your servers (* = current), switch with `guild <no.>`:",
+		msgInvalidGuild:        "**%s** is not one of your servers",
+		msgNoSharedGuild:       "You are not on any server I manage",
+		msgPickGuild:           "For which server? Write `edit <no.>`:\n",
+		msgSessionRestored:     "The bot was restarted, your session continues.",
+		msgTermModeCurrent:     "Terminal mode of this server: **%s** (available: %s)",
+		msgTermModeSet:         "Terminal mode of this server set to **%s**",
+		msgAuditList:           "Audit log:",
+		msgAuditTableHeader:    "Time             | User                 | Action   | Target                 | Change\n",
+		msgAuditEmpty:          "No audit log entries found",
+		msgAuditChannelCurrent: "The audit log is mirrored to %s. Turn this off with `audit channel off`",
+		msgAuditChannelNone:    "The audit log is not mirrored to any channel. Set one with `audit channel <channel>`",
+		msgAuditChannelSet:     "The audit log is now mirrored to %s",
+		msgAuditChannelOff:     "The audit log is no longer mirrored",
+		msgAuditChannelInvalid: "**%s** is not a text channel of this server",
+		msgErrorNotFound:       "No error with the reference **%s** found on this server",
+		msgErrorReport:         "Error **%s** from %s\nUser: %s\nCommand: %s\nInput: `%s`",
+		msgFinalTopic:          "%s | Type: %s | Date: %s | Modules: %s",
+		msgFinalInfo:           "**%s** (%s)\nType: %s\nDate: %s\nModules: %s\nMembers: **%d**",
+		msgFinalDateUnknown:    "not known yet",
+		msgTermModeUnknown:     "Unknown terminal mode \"%s\". Available: %s",
+		msgDMsClosed:           "%s, I can not send you direct messages. Please allow direct messages from server members and try again.",
+		msgTerminalOpened:      "%s, your terminal is waiting for you in %s.",
+		msgPrivateTerminal:     "This channel is only visible to you and will be deleted once the terminal is closed.",
+		msgHelpList:            "Available commands:",
+		msgHelpFooter:          "More about a command with `%shelp <command>`",
+		msgHelpUsage:           "Usage: `%s`",
+		msgHelpExamples:        "Examples:",
+		msgHelpUnknown:         "There is no command **%s**. `%shelp` lists all commands",
+		msgHelpHelp:            "Lists all commands or explains a single one",
+		msgHelpSearch:          "Searches finals by name, abbreviation or ID. Filters: `major:<major>` `sem:<semester>` `type:<type>`, `--all` shows every match",
+		msgHelpBrowse:          "Lists all majors or the finals of a major",
+		msgHelpJoin:            "Joins finals by ID, abbreviation or name. `all` with filters joins every final they match",
+		msgHelpLeave:           "Leaves finals by ID, abbreviation or name. `all` leaves every final, optionally filtered",
+		msgHelpListFinals:      "Lists your finals",
+		msgHelpNext:            "Shows the next page of a long list",
+		msgHelpPrev:            "Shows the previous page of a long list",
+		msgHelpLanguage:        "Shows or changes your language",
+		msgHelpGuild:           "Lists your servers or switches to another one",
+		msgHelpDefaultLanguage: "Shows or changes the server's default language",
+		msgHelpReindex:         "Rebuilds the server's search index",
+		msgHelpTerminals:       "Lists all open terminals",
+		msgHelpKill:            "Kills the terminals of a user or channel",
+		msgHelpTermMode:        "Shows or changes where terminals are opened",
+		msgHelpAudit:           "Shows the audit log, filtered by user, final, action or date",
+		msgHelpAuditChannel:    "Shows or changes the channel the audit log is mirrored to",
+		msgHelpError:           "Shows an error a user reported by its reference",
+		msgHelpAdminTerminal:   "Opens an admin terminal",
+		msgHelpEdit:            "Opens a terminal to manage your finals",
+		msgHelpPing:            "Checks whether the bot responds",
+		msgArgUnclosedQuote:    "There is a quote missing its closing quote in **%s**",
+		msgArgUnknownFlag:      "**%s** is not a valid option for this command",
+		msgArgMissingValue:     "**%s** is missing a value",
+		msgArgNotANumber:       "**%s** is not a valid number",
+		msgArgNotAnID:          "**%s** is not a valid ID",
+		msgArgNotADate:         "**%s** is not a valid date, expected YYYY-MM-DD",
+		msgBotStopping:         "The bot is restarting, your session continues afterwards",
+		msgShuttingDown:        "The bot is restarting right now. Please try again in a moment.",
+		msgJobDelayed:          "Discord is only letting me work slowly right now, your request is still being processed...",
+		msgJobRetrying:         "Discord can not be reached right now, trying again in %s...",
+
+		msgStatsSummary:         "**%d** users are in **%d** finals, **%d** enrollments in total. **%d** of %d finals have no members",
+		msgStatsTop:             "The %d finals with the most members:",
+		msgStatsFinalsList:      "Members per final:",
+		msgStatsFinalsHeader:    "  ID   |    Members | Final\n",
+		msgStatsMajorsList:      "Enrollments per major:",
+		msgStatsMajorsHeader:    "Major        |    Finals | Enrollments\n",
+		msgStatsSemestersList:   "Enrollments per semester:",
+		msgStatsSemestersHeader: "Semester     |    Finals | Enrollments\n",
+		msgStatsEmptyList:       "Finals without members:",
+		msgStatsNoEmpty:         "Every final has at least one member",
+		msgStatsGrowthList:      "Joins and leaves of the last %d days:",
+		msgStatsGrowthHeader:    "Day        |     Joins |    Leaves | Enrollments\n",
+		msgStatsNoGrowth:        "Nobody joined or left a final in the last %d days",
+		msgStatsUnknownFormat:   "Unknown format **%s**, available are csv and md",
+		msgStatsReportSent:      "Enrollment report as %s:",
+		msgStatsReportTitle:     "Enrollment report",
+		msgStatsReportFinalCols: "ID,Final,Modules,Members",
+		msgStatsReportGroupCols: "Group,Finals,Enrollments",
+		msgStatsReportDayCols:   "Day,Joins,Leaves,Enrollments",
+		msgHelpStatsSummary:     "Shows an overview of the enrollments and the finals with the most members",
+		msgHelpStatsFinals:      "Shows the members of every final",
+		msgHelpStatsMajors:      "Shows the enrollments per major",
+		msgHelpStatsSemesters:   "Shows the enrollments per semester of a major",
+		msgHelpStatsEmpty:       "Shows the finals without members",
+		msgHelpStatsGrowth:      "Shows the joins and leaves per day, from the audit log",
+		msgHelpStatsReport:      "Builds a report of all statistics as CSV or markdown",
+
+		msgVoiceNotFinalChannel: "Voice rooms can only be created in the channel of a final",
+		msgVoiceRoomLimit:       "This final already has %d voice rooms. A new one can be created once one of them is empty",
+		msgVoiceRoomCreated:     "Created voice room %s. It is deleted once it stays empty for %d minutes",
+		msgVoiceRoomName:        "%s study room %d",
+		msgFinalVoiceCurrentOn:  "Every final gets a voice channel. Turn this off with `voice off`",
+		msgFinalVoiceCurrentOff: "Finals do not get a voice channel of their own. Turn this on with `voice on`",
+		msgFinalVoiceOn:         "Every final now gets a voice channel, %d were created",
+		msgFinalVoiceOff:        "Finals no longer get a voice channel, %d were deleted",
+		msgFinalVoiceUnknown:    "Unknown setting \"%s\". Available: on, off",
+		msgHelpVoice:            "Creates a voice room in the channel of a final, which is deleted once it stays empty",
+		msgHelpFinalVoice:       "Shows or changes whether every final gets a voice channel",
+	},
+}
+
+// tr returns the text for key in the given locale, formatted with a.
+// Missing translations fall back to the default locale, and then to the key itself.
+func tr(l locale, key msgKey, a ...interface{}) string {
+	text, ok := catalog[l][key]
+	if !ok {
+		text, ok = catalog[defaultLocale][key]
+	}
+	if !ok {
+		return string(key)
+	}
+	if len(a) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, a...)
+}
+
+// parseLocale resolves user input like "EN" or "de" to a supported locale
+func parseLocale(s string) (locale, bool) {
+	l := locale(strings.ToLower(strings.TrimSpace(s)))
+	_, ok := catalog[l]
+	return l, ok
+}
+
+func localeList() string {
+	names := make([]string, len(locales))
+	for i, l := range locales {
+		names[i] = string(l)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,159 @@
+package schooldiscord
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var levelNames = map[logLevel]string{
+	levelDebug: "DEBUG",
+	levelInfo:  "INFO",
+	levelWarn:  "WARN",
+	levelError: "ERROR",
+}
+
+func parseLogLevel(s string) (logLevel, bool) {
+	for l, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return l, true
+		}
+	}
+	return levelInfo, false
+}
+
+// logger writes leveled log lines with key/value pairs, as text or as JSON.
+// Loggers made by With share the level and format of the logger they were made from
+type logger struct {
+	out   *log.Logger
+	conf  *logConf
+	attrs []interface{} // key/value pairs added to every line
+}
+
+type logConf struct {
+	level logLevel
+	json  bool
+}
+
+func newLogger(out *log.Logger) *logger {
+	return &logger{out: out, conf: &logConf{level: levelInfo}}
+}
+
+// configure sets the lowest level logged and the format, "text" or "json"
+func (l *logger) configure(level string, format string) {
+	if lvl, ok := parseLogLevel(level); ok {
+		l.conf.level = lvl
+	} else if level != "" {
+		l.Warn("Unknown log level, using INFO", "level", level)
+	}
+	l.conf.json = strings.EqualFold(format, "json")
+	if l.conf.json {
+		// JSON lines carry their own time, the prefix of out would make them invalid.
+		// They are still written through out, so that they do not interleave with other lines written to it
+		l.out.SetPrefix("")
+		l.out.SetFlags(0)
+	}
+}
+
+// With returns a logger that adds the key/value pairs kv to every line
+func (l *logger) With(kv ...interface{}) *logger {
+	attrs := make([]interface{}, 0, len(l.attrs)+len(kv))
+	attrs = append(attrs, l.attrs...)
+	attrs = append(attrs, kv...)
+	return &logger{out: l.out, conf: l.conf, attrs: attrs}
+}
+
+func (l *logger) Debug(msg string, kv ...interface{}) { l.log(levelDebug, msg, kv) }
+func (l *logger) Info(msg string, kv ...interface{})  { l.log(levelInfo, msg, kv) }
+func (l *logger) Warn(msg string, kv ...interface{})  { l.log(levelWarn, msg, kv) }
+func (l *logger) Error(msg string, kv ...interface{}) { l.log(levelError, msg, kv) }
+
+func (l *logger) log(level logLevel, msg string, kv []interface{}) {
+	if level < l.conf.level {
+		return
+	}
+
+	all := make([]interface{}, 0, len(l.attrs)+len(kv))
+	all = append(all, l.attrs...)
+	all = append(all, kv...)
+
+	if l.conf.json {
+		l.out.Output(3, string(jsonLine(level, msg, all)))
+		return
+	}
+	l.out.Print(textLine(level, msg, all))
+}
+
+func textLine(level logLevel, msg string, kv []interface{}) string {
+	b := strings.Builder{}
+	b.WriteString("level=" + levelNames[level])
+	b.WriteString(" msg=" + quoteIfNeeded(msg))
+	for i := 0; i < len(kv); i += 2 {
+		b.WriteString(" " + fmt.Sprint(kv[i]) + "=")
+		if i+1 < len(kv) {
+			b.WriteString(quoteIfNeeded(valueString(kv[i+1])))
+		}
+	}
+	return b.String()
+}
+
+func jsonLine(level logLevel, msg string, kv []interface{}) []byte {
+	b := strings.Builder{}
+	b.WriteString(`{"time":` + strconv.Quote(time.Now().Format(time.RFC3339Nano)))
+	b.WriteString(`,"level":` + strconv.Quote(levelNames[level]))
+	b.WriteString(`,"msg":` + jsonString(msg))
+	for i := 0; i < len(kv); i += 2 {
+		b.WriteString("," + jsonString(fmt.Sprint(kv[i])) + ":")
+		if i+1 >= len(kv) {
+			b.WriteString("null")
+			continue
+		}
+		switch v := kv[i+1].(type) {
+		case error, fmt.Stringer:
+			b.WriteString(jsonString(valueString(v)))
+		default:
+			enc, err := json.Marshal(v)
+			if err != nil {
+				enc = []byte(jsonString(fmt.Sprint(v)))
+			}
+			b.Write(enc)
+		}
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+func valueString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "<nil>"
+	case error:
+		return v.Error()
+	}
+	return fmt.Sprint(v)
+}
+
+func jsonString(s string) string {
+	enc, _ := json.Marshal(s)
+	return string(enc)
+}
+
+// quotes s if it would not be read back as a single value
+func quoteIfNeeded(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
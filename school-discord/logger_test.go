@@ -0,0 +1,52 @@
+package schooldiscord
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// the log of the service is shared with code that writes plain lines to it
+func TestLoggerJSONLinesFromSeveralGoroutines(t *testing.T) {
+	var buf bytes.Buffer
+	out := log.New(&buf, "school-discord ", log.LstdFlags)
+	root := newLogger(out)
+	root.configure("info", "json")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		l := root.With("worker", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				l.Info("Working", "step", j)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			out.Print("plain line")
+		}
+	}()
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 9*50 {
+		t.Fatalf("got %d lines, want %d", len(lines), 9*50)
+	}
+	for _, line := range lines {
+		if line == "plain line" {
+			continue
+		}
+		var v map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package schooldiscord
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A small implementation of the prometheus text format, enough for the few metrics the bot exposes
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// counterVec is a counter with labels. A counter without labels is a counterVec with no label names
+type counterVec struct {
+	name, help string
+	labels     []string
+
+	mu   sync.Mutex
+	vals map[string]float64 // by label values, joined by labelSep
+}
+
+// gaugeFunc is a gauge whose value is read when the metrics are scraped
+type gaugeFunc struct {
+	name, help string
+	f          func() float64
+}
+
+// histogramVec tracks the distribution of observed values, like the duration of requests
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64 // upper bounds, ascending
+
+	mu   sync.Mutex
+	vals map[string]*histogramVals
+}
+
+type histogramVals struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+const labelSep = "\xff"
+
+// the default buckets of the prometheus client libraries, in seconds
+var defaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metricRegistry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+func newMetricRegistry() *metricRegistry {
+	return &metricRegistry{metrics: make(map[string]metric)}
+}
+
+func (r *metricRegistry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics[name] = m
+}
+
+func (r *metricRegistry) counter(name, help string, labels ...string) *counterVec {
+	c := &counterVec{name: name, help: help, labels: labels, vals: make(map[string]float64)}
+	r.register(name, c)
+	return c
+}
+
+func (r *metricRegistry) gauge(name, help string, f func() float64) {
+	r.register(name, &gaugeFunc{name: name, help: help, f: f})
+}
+
+func (r *metricRegistry) histogram(name, help string, buckets []float64, labels ...string) *histogramVec {
+	h := &histogramVec{name: name, help: help, labels: labels, buckets: buckets, vals: make(map[string]*histogramVals)}
+	r.register(name, h)
+	return h
+}
+
+// writes all metrics in the prometheus text format, sorted by name
+func (r *metricRegistry) write(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for n := range r.metrics {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	ms := make([]metric, len(names))
+	for i, n := range names {
+		ms[i] = r.metrics[n]
+	}
+	r.mu.Unlock()
+
+	for _, m := range ms {
+		m.write(w)
+	}
+}
+
+// inc adds 1 to the counter with the given label values
+func (c *counterVec) inc(labelVals ...string) {
+	c.add(1, labelVals...)
+}
+
+func (c *counterVec) add(v float64, labelVals ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.vals[strings.Join(labelVals, labelSep)] += v
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, k := range sortedKeys(c.vals) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labelString(c.labels, k, ""), formatValue(c.vals[k]))
+	}
+}
+
+func (g *gaugeFunc) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", g.name, g.help, g.name, g.name, formatValue(g.f()))
+}
+
+func (h *histogramVec) observe(v float64, labelVals ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := strings.Join(labelVals, labelSep)
+	hv, ok := h.vals[k]
+	if !ok {
+		hv = &histogramVals{counts: make([]uint64, len(h.buckets))}
+		h.vals[k] = hv
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			hv.counts[i]++
+			break
+		}
+	}
+	hv.sum += v
+	hv.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.vals))
+	for k := range h.vals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		hv := h.vals[k]
+		cum := uint64(0)
+		for i, b := range h.buckets {
+			cum += hv.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelString(h.labels, k, formatValue(b)), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelString(h.labels, k, "+Inf"), hv.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labelString(h.labels, k, ""), formatValue(hv.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labelString(h.labels, k, ""), hv.count)
+	}
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formats the label values joined in key as {name="value",...}, adding the le label of histogram buckets if given
+func labelString(names []string, key string, le string) string {
+	pairs := make([]string, 0, len(names)+1)
+	if len(names) > 0 {
+		for i, v := range strings.Split(key, labelSep) {
+			if i < len(names) {
+				pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", names[i], escapeLabel(v)))
+			}
+		}
+	}
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf("le=\"%s\"", le))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return fmt.Sprint(v)
+}
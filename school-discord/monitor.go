@@ -0,0 +1,151 @@
+package schooldiscord
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	simpsql "github.com/Petrify/simp-core/sql"
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	metrics = newMetricRegistry()
+
+	mCommands = metrics.counter("schooldiscord_commands_total",
+		"Commands run, by command and outcome.", "command", "outcome")
+	mMemberships = metrics.counter("schooldiscord_final_memberships_total",
+		"Finals joined and left by users.", "action")
+	mTermTimeouts = metrics.counter("schooldiscord_terminal_timeouts_total",
+		"Terminals closed because they were idle for too long.")
+	mDiscordRequests = metrics.histogram("schooldiscord_discord_request_duration_seconds",
+		"Duration of requests to the discord API, by method and status code.", defaultBuckets, "method", "code")
+	mDBQueries = metrics.histogram("schooldiscord_db_query_duration_seconds",
+		"Duration of database operations, by operation.", defaultBuckets, "op")
+)
+
+// how long the readiness check waits for the database to answer
+const readyTimeout = 2 * time.Second
+
+// registers the metrics that are read from the service when they are scraped
+func (s *Service) registerGauges() {
+	metrics.gauge("schooldiscord_open_terminals", "Terminals currently open.", func() float64 {
+		s.termMu.Lock()
+		defer s.termMu.Unlock()
+		return float64(len(s.terminals))
+	})
+	metrics.gauge("schooldiscord_guilds_loaded", "Guilds the bot has loaded.", func() float64 {
+		s.guildMu.RLock()
+		defer s.guildMu.RUnlock()
+		return float64(len(s.guilds))
+	})
+	metrics.gauge("schooldiscord_discord_gateway_up", "Whether the discord gateway is connected.", func() float64 {
+		return float64(atomic.LoadInt32(&s.gatewayUp))
+	})
+}
+
+// observeDB measures a database operation and marks the error it returns if the database could not be reached.
+// Use it as `defer observeDB("name", &err)()` in functions with a named error result
+func observeDB(op string, err *error) func() {
+	start := time.Now()
+	return func() {
+		mDBQueries.observe(time.Since(start).Seconds(), op)
+		*err = dbError(*err)
+	}
+}
+
+// timedTransport measures the requests discordgo makes to the discord API
+type timedTransport struct {
+	next http.RoundTripper
+}
+
+func (t timedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	mDiscordRequests.observe(time.Since(start).Seconds(), req.Method, code)
+	return resp, err
+}
+
+// instruments ds and keeps track of whether its gateway is connected
+func (s *Service) monitorDiscord(ds *discordgo.Session) {
+	next := ds.Client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	ds.Client.Transport = timedTransport{next}
+
+	ds.AddHandler(func(_ *discordgo.Session, _ *discordgo.Connect) {
+		atomic.StoreInt32(&s.gatewayUp, 1)
+	})
+	ds.AddHandler(func(_ *discordgo.Session, _ *discordgo.Disconnect) {
+		atomic.StoreInt32(&s.gatewayUp, 0)
+	})
+}
+
+// startMonitor serves /metrics, /healthz and /readyz on addr
+func (s *Service) startMonitor(addr string) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.write(w)
+	})
+
+	// alive for as long as the service has not been stopped
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if s.isStopping() {
+			http.Error(w, "stopping", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	// ready to take commands once connected to discord and the database
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+		defer cancel()
+
+		gateway := atomic.LoadInt32(&s.gatewayUp) == 1
+		dbErr := simpsql.DB.PingContext(ctx)
+		stopping := s.isStopping()
+
+		status := http.StatusOK
+		if !gateway || dbErr != nil || stopping {
+			status = http.StatusServiceUnavailable
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "discord gateway: %s\ndatabase: %s\nstopping: %t\n", upDown(gateway), upDown(dbErr == nil), stopping)
+	})
+
+	s.monitor = &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := s.monitor.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.log.Error("Monitoring endpoint stopped", "addr", addr, "err", err)
+		}
+	}()
+	s.log.Info("Serving metrics and health checks", "addr", addr)
+}
+
+func (s *Service) stopMonitor(ctx context.Context) {
+	if s.monitor == nil {
+		return
+	}
+	if err := s.monitor.Shutdown(ctx); err != nil {
+		s.log.Warn("Could not stop monitoring endpoint", "err", err)
+	}
+}
+
+func upDown(up bool) string {
+	if up {
+		return "up"
+	}
+	return "down"
+}
@@ -0,0 +1,132 @@
+package schooldiscord
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	msgLimit     = 2000 // discord's maximum message length
+	pageSize     = 15   // lines per page of paged terminal output
+	codeBlockTag = "```"
+)
+
+// splitMessage splits text into chunks of at most limit bytes along line breaks.
+// Code blocks that span a split are closed at the end of a chunk and reopened at the start of the next one.
+func splitMessage(text string, limit int) []string {
+	if len(text) <= limit {
+		return []string{text}
+	}
+
+	var (
+		chunks  []string
+		chunk   strings.Builder
+		inBlock bool
+	)
+
+	flush := func() {
+		if inBlock {
+			chunk.WriteString(codeBlockTag)
+		}
+		chunks = append(chunks, chunk.String())
+		chunk.Reset()
+		if inBlock {
+			chunk.WriteString(codeBlockTag + "\n")
+		}
+	}
+
+	write := func(text string) {
+		chunk.WriteString(text)
+		if strings.Count(text, codeBlockTag)%2 == 1 {
+			inBlock = !inBlock
+		}
+	}
+
+	// room that has to be left in a chunk to close an open code block
+	reserve := len(codeBlockTag)
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for chunk.Len()+len(line)+reserve > limit {
+			room := limit - reserve - chunk.Len()
+			if room <= 0 || len(line) <= limit-2*reserve-1 {
+				flush()
+				continue
+			}
+			// a single line that does not fit into a message on its own
+			cut := runeBoundary(line, room)
+			write(line[:cut])
+			line = line[cut:]
+			flush()
+		}
+		write(line)
+	}
+
+	if chunk.Len() > 0 {
+		chunks = append(chunks, chunk.String())
+	}
+	return chunks
+}
+
+// returns the largest index <= n that does not split a rune in s
+func runeBoundary(s string, n int) int {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return n
+}
+
+// pager holds the pages of a long terminal output
+type pager struct {
+	pages []string
+	cur   int
+}
+
+// newPager lays out lines as a code block table with head as the table's first line.
+// title is shown above the table on every page
+func newPager(title string, head string, lines []string, perPage int) *pager {
+	p := &pager{}
+
+	for start := 0; ; start += perPage {
+		end := min(start+perPage, len(lines))
+
+		page := strings.Builder{}
+		page.WriteString(title)
+		page.WriteString(codeBlockTag)
+		page.WriteString(head)
+		for _, l := range lines[start:end] {
+			page.WriteString(l)
+		}
+		page.WriteString(codeBlockTag)
+		p.pages = append(p.pages, page.String())
+
+		if end >= len(lines) {
+			break
+		}
+	}
+
+	return p
+}
+
+func (p *pager) len() int {
+	return len(p.pages)
+}
+
+func (p *pager) page() string {
+	return p.pages[p.cur]
+}
+
+func (p *pager) next() bool {
+	if p.cur+1 >= len(p.pages) {
+		return false
+	}
+	p.cur++
+	return true
+}
+
+func (p *pager) prev() bool {
+	if p.cur == 0 {
+		return false
+	}
+	p.cur--
+	return true
+}
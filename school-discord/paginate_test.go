@@ -0,0 +1,142 @@
+package schooldiscord
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"fits", "short", msgLimit, []string{"short"}},
+		{"exactly the limit", strings.Repeat("a", msgLimit), msgLimit, []string{strings.Repeat("a", msgLimit)}},
+		{"at line breaks", "aaaa\nbbbb\ncccc", 13, []string{"aaaa\nbbbb\n", "cccc"}},
+		{"overlong line", "0123456789abcdef\nxy", 10, []string{"0123456", "789abcd", "ef\nxy"}},
+		{"code block", "abc\n```\n1111\n2222\n3333\n```\nend", 22, []string{"abc\n```\n1111\n2222\n```", "```\n3333\n```\nend"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := splitMessage(tc.text, tc.limit); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSplitMessageAtDiscordLimit(t *testing.T) {
+	var lines strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&lines, "line %d\n", i)
+	}
+
+	tests := []struct {
+		name  string
+		text  string
+		block bool // text is a single code block
+	}{
+		{"lines", lines.String(), false},
+		{"overlong line", strings.Repeat("a", 4500), false},
+		{"overlong line of umlauts", strings.Repeat("ä", 1500), false},
+		{"code block", codeBlockTag + "\n" + lines.String() + codeBlockTag, true},
+		{"overlong line in code block", codeBlockTag + "\n" + strings.Repeat("a", 4500) + "\n" + codeBlockTag, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			chunks := splitMessage(tc.text, msgLimit)
+			if len(chunks) < 2 {
+				t.Fatalf("got %d chunks, want the text to be split", len(chunks))
+			}
+			for i, c := range chunks {
+				if len(c) > msgLimit {
+					t.Errorf("chunk %d has %d bytes", i, len(c))
+				}
+				if !utf8.ValidString(c) {
+					t.Errorf("chunk %d splits a rune", i)
+				}
+				if tc.block && (!strings.HasPrefix(c, codeBlockTag) || !strings.HasSuffix(c, codeBlockTag)) {
+					t.Errorf("chunk %d is not a complete code block: %q", i, c)
+				}
+			}
+
+			joined := strings.Join(chunks, "")
+			if tc.block {
+				joined = strings.ReplaceAll(joined, codeBlockTag+codeBlockTag+"\n", "")
+			}
+			if joined != tc.text {
+				t.Error("chunks do not add up to the text")
+			}
+		})
+	}
+}
+
+func TestNewPager(t *testing.T) {
+	tests := []struct {
+		lines   int
+		perPage int
+		pages   int
+		last    int // lines on the last page
+	}{
+		{0, pageSize, 1, 0},
+		{1, pageSize, 1, 1},
+		{pageSize, pageSize, 1, pageSize},
+		{pageSize + 1, pageSize, 2, 1},
+		{3*pageSize - 1, pageSize, 3, pageSize - 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprint(tc.lines), func(t *testing.T) {
+			lines := make([]string, tc.lines)
+			for i := range lines {
+				lines[i] = fmt.Sprintf("row %d\n", i)
+			}
+
+			p := newPager("Title\n", "head\n", lines, tc.perPage)
+			if p.len() != tc.pages {
+				t.Fatalf("got %d pages, want %d", p.len(), tc.pages)
+			}
+
+			for i, page := range p.pages {
+				if !strings.HasPrefix(page, "Title\n"+codeBlockTag+"head\n") || !strings.HasSuffix(page, codeBlockTag) {
+					t.Errorf("page %d is not laid out as a table: %q", i, page)
+				}
+			}
+			if got := strings.Count(p.pages[len(p.pages)-1], "row "); got != tc.last {
+				t.Errorf("last page has %d lines, want %d", got, tc.last)
+			}
+			if tc.lines > 0 && !strings.Contains(p.pages[len(p.pages)-1], fmt.Sprintf("row %d\n", tc.lines-1)) {
+				t.Error("last line is not on the last page")
+			}
+		})
+	}
+}
+
+func TestPagerNavigation(t *testing.T) {
+	p := newPager("", "", []string{"a\n", "b\n", "c\n"}, 1)
+
+	if p.prev() {
+		t.Error("went back from the first page")
+	}
+	for i := 1; i < 3; i++ {
+		if !p.next() {
+			t.Fatalf("could not go to page %d", i+1)
+		}
+	}
+	if p.next() {
+		t.Error("went past the last page")
+	}
+	if want := codeBlockTag + "c\n" + codeBlockTag; p.page() != want {
+		t.Errorf("got %q, want %q", p.page(), want)
+	}
+	if !p.prev() || p.cur != 1 {
+		t.Errorf("prev went to page %d, want 2", p.cur+1)
+	}
+}
@@ -0,0 +1,289 @@
+package schooldiscord
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	jobQueueSize   = 256
+	jobMaxAttempts = 5
+	jobBaseBackoff = 500 * time.Millisecond
+	jobMaxBackoff  = 30 * time.Second
+	jobDelayNotice = 3 * time.Second // how long a job may take before the user is told it is delayed
+)
+
+var errQueueStopped = errors.New("job queue stopped")
+
+// notifyFunc tells the user who requested a job about its progress, like terminal.PrintMsg
+type notifyFunc func(key msgKey, a ...interface{}) error
+
+// discordJob is a change to a guild on discord, run by the guild's jobQueue
+type discordJob struct {
+	key    string
+	run    func(attempt int) error
+	ctx    context.Context
+	notify notifyFunc
+
+	done chan struct{}
+	err  error
+
+	started  bool // guarded by the mu of the queue
+	finished bool
+}
+
+// jobQueue runs the discord changes of a guild one after another, retrying them while discord is unavailable.
+// Every job has a key naming the change it makes. While a job is queued or running,
+// jobs with the same key are not queued again but wait for the result of the first
+type jobQueue struct {
+	mu      sync.Mutex
+	pending map[string]*discordJob
+
+	jobs     chan *discordJob
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	log *logger
+}
+
+func newJobQueue(log *logger) *jobQueue {
+	q := &jobQueue{
+		pending: make(map[string]*discordJob),
+		jobs:    make(chan *discordJob, jobQueueSize),
+		quit:    make(chan struct{}),
+		log:     log,
+	}
+	go q.work()
+	return q
+}
+
+// do queues run under key and waits for it to finish. notify may be nil.
+// Jobs that have not started yet when ctx is done are dropped, a running job is always waited for,
+// so that the caller knows whether the change was made
+func (q *jobQueue) do(ctx context.Context, key string, notify notifyFunc, run func(attempt int) error) error {
+
+	q.mu.Lock()
+	select {
+	case <-q.quit:
+		q.mu.Unlock()
+		return discordError(errQueueStopped)
+	default:
+	}
+	job, ok := q.pending[key]
+	if !ok {
+		job = &discordJob{
+			key:    key,
+			run:    run,
+			ctx:    ctx,
+			notify: notify,
+			done:   make(chan struct{}),
+		}
+		q.pending[key] = job
+	}
+	q.mu.Unlock()
+
+	if !ok {
+		select {
+		case q.jobs <- job:
+		case <-ctx.Done():
+			q.drop(job, ctx.Err())
+		case <-q.quit:
+			q.drop(job, errQueueStopped)
+		}
+	}
+
+	notice := time.NewTimer(jobDelayNotice)
+	defer notice.Stop()
+
+	quit, cancel := q.quit, ctx.Done()
+	for {
+		select {
+		case <-job.done:
+			return discordError(job.err)
+		case <-notice.C:
+			if notify != nil {
+				notify(msgJobDelayed)
+			}
+		case <-quit:
+			// the worker may have stopped before taking the job. A running job is finished by the worker
+			q.drop(job, errQueueStopped)
+			quit = nil
+		case <-cancel:
+			// a job that waits for the result of another caller's job only stops waiting for it
+			if !ok && q.drop(job, ctx.Err()) || ok && !q.isStarted(job) {
+				return discordError(ctx.Err())
+			}
+			cancel = nil
+		}
+	}
+}
+
+// stop finishes the job running right now and drops all others
+func (q *jobQueue) stop() {
+	q.quitOnce.Do(func() { close(q.quit) })
+}
+
+func (q *jobQueue) work() {
+	for {
+		select {
+		case <-q.quit:
+			q.drain()
+			return
+		case job := <-q.jobs:
+			if q.begin(job) {
+				q.finish(job, q.attempt(job))
+			}
+		}
+	}
+}
+
+// runs a job until it succeeds, fails for good, its context is done or the queue is stopped
+func (q *jobQueue) attempt(job *discordJob) error {
+	for i := 0; ; i++ {
+		if err := job.ctx.Err(); err != nil && i == 0 {
+			return err
+		}
+
+		err := job.run(i)
+		wait, retry := retryAfter(err, i)
+		if !retry || i+1 == jobMaxAttempts {
+			return err
+		}
+
+		q.log.Warn("Discord job failed, retrying", "job", job.key, "attempt", i+1, "wait", wait, "err", err)
+		if job.notify != nil && wait >= jobDelayNotice {
+			job.notify(msgJobRetrying, wait.Round(time.Second))
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-job.ctx.Done():
+			return err
+		case <-q.quit:
+			return err
+		}
+	}
+}
+
+// begin marks a job as running, unless it was dropped while it was queued
+func (q *jobQueue) begin(job *discordJob) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job.finished {
+		return false
+	}
+	job.started = true
+	return true
+}
+
+func (q *jobQueue) isStarted(job *discordJob) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return job.started
+}
+
+// drop finishes a job with err if it has not started yet and reports whether it did not
+func (q *jobQueue) drop(job *discordJob, err error) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job.started {
+		return false
+	}
+	q.finishLocked(job, err)
+	return true
+}
+
+func (q *jobQueue) finish(job *discordJob, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.finishLocked(job, err)
+}
+
+func (q *jobQueue) finishLocked(job *discordJob, err error) {
+	if job.finished {
+		return
+	}
+	job.finished = true
+	if q.pending[job.key] == job {
+		delete(q.pending, job.key)
+	}
+	job.err = err
+	close(job.done)
+}
+
+// fails every job that is still queued
+func (q *jobQueue) drain() {
+	for {
+		select {
+		case job := <-q.jobs:
+			q.finish(job, errQueueStopped)
+		default:
+			return
+		}
+	}
+}
+
+// retryAfter reports whether err is discord being busy or unreachable, and how long to wait before trying again
+func retryAfter(err error, attempt int) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	backoff := jobBaseBackoff << uint(attempt)
+	if backoff > jobMaxBackoff {
+		backoff = jobMaxBackoff
+	}
+	// spread out the retries of jobs that failed at the same time
+	backoff += time.Duration(rand.Int63n(int64(backoff) / 2))
+
+	var rerr *discordgo.RESTError
+	if errors.As(err, &rerr) && rerr.Response != nil {
+		code := rerr.Response.StatusCode
+		if code != http.StatusTooManyRequests && code < 500 {
+			return 0, false
+		}
+		if s := rerr.Response.Header.Get("Retry-After"); s != "" {
+			if secs, perr := strconv.ParseFloat(s, 64); perr == nil {
+				return time.Duration(secs * float64(time.Second)), true
+			}
+		}
+		return backoff, true
+	}
+
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		return backoff, true
+	}
+
+	// discordgo retries bad gateways itself and gives up with an error of its own
+	if strings.HasPrefix(err.Error(), "Exceeded Max retries") {
+		return backoff, true
+	}
+	return 0, false
+}
+
+// retry runs f like a job of a jobQueue, for discord calls that do not change the guild, like sending messages
+func retry(ctx context.Context, f func() error) (err error) {
+	for i := 0; i < jobMaxAttempts; i++ {
+		err = f()
+		wait, ok := retryAfter(err, i)
+		if !ok || i+1 == jobMaxAttempts {
+			return discordError(err)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return discordError(err)
+		}
+	}
+	return
+}
@@ -0,0 +1,159 @@
+package schooldiscord
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+)
+
+func newTestQueue(t *testing.T) *jobQueue {
+	q := newJobQueue(newLogger(log.New(ioutil.Discard, "", 0)))
+	t.Cleanup(q.stop)
+	return q
+}
+
+// doAsync runs q.do in a goroutine and returns where its error is sent
+func doAsync(ctx context.Context, q *jobQueue, key string, run func(int) error) <-chan error {
+	res := make(chan error, 1)
+	go func() { res <- q.do(ctx, key, nil, run) }()
+	return res
+}
+
+func waitResult(t *testing.T, res <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-res:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("job queue did not return")
+		return nil
+	}
+}
+
+// blockQueue starts a job that runs until release is closed
+func blockQueue(t *testing.T, q *jobQueue) (release chan struct{}, res <-chan error) {
+	running := make(chan struct{})
+	release = make(chan struct{})
+	res = doAsync(context.Background(), q, "block", func(int) error {
+		close(running)
+		<-release
+		return nil
+	})
+	<-running
+	return release, res
+}
+
+func TestJobQueueStopped(t *testing.T) {
+	q := newTestQueue(t)
+	q.stop()
+
+	ran := false
+	err := waitResult(t, doAsync(context.Background(), q, "job", func(int) error {
+		ran = true
+		return nil
+	}))
+	if !errors.Is(err, errQueueStopped) {
+		t.Errorf("got %v, want errQueueStopped", err)
+	}
+	if ran {
+		t.Error("job ran on a stopped queue")
+	}
+}
+
+func TestJobQueueStopDropsQueuedJobs(t *testing.T) {
+	q := newTestQueue(t)
+	release, blocked := blockQueue(t, q)
+
+	queued := doAsync(context.Background(), q, "queued", func(int) error {
+		t.Error("queued job ran after the queue was stopped")
+		return nil
+	})
+	time.Sleep(10 * time.Millisecond)
+	q.stop()
+
+	if err := waitResult(t, queued); !errors.Is(err, errQueueStopped) {
+		t.Errorf("queued job: got %v, want errQueueStopped", err)
+	}
+
+	// the running job is still waited for
+	close(release)
+	if err := waitResult(t, blocked); err != nil {
+		t.Errorf("running job: %v", err)
+	}
+}
+
+func TestJobQueueCancelledBeforeStart(t *testing.T) {
+	q := newTestQueue(t)
+	release, blocked := blockQueue(t, q)
+	defer func() {
+		close(release)
+		waitResult(t, blocked)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := doAsync(ctx, q, "queued", func(int) error {
+		t.Error("cancelled job ran")
+		return nil
+	})
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	if err := waitResult(t, queued); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestJobQueueWaitsForRunningJob(t *testing.T) {
+	q := newTestQueue(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	running := make(chan struct{})
+	release := make(chan struct{})
+	res := doAsync(ctx, q, "job", func(int) error {
+		close(running)
+		<-release
+		return nil
+	})
+	<-running
+	cancel()
+
+	select {
+	case err := <-res:
+		t.Fatalf("returned before the running job was done: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if err := waitResult(t, res); err != nil {
+		t.Errorf("got %v, want the result of the job", err)
+	}
+}
+
+func TestJobQueueSameKeyWaitsForFirst(t *testing.T) {
+	q := newTestQueue(t)
+	release, blocked := blockQueue(t, q)
+
+	calls := 0
+	first := doAsync(context.Background(), q, "same", func(int) error {
+		calls++
+		return nil
+	})
+	time.Sleep(10 * time.Millisecond)
+	second := doAsync(context.Background(), q, "same", func(int) error {
+		calls++
+		return nil
+	})
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	for _, res := range []<-chan error{blocked, first, second} {
+		if err := waitResult(t, res); err != nil {
+			t.Error(err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("job with the same key ran %d times, want 1", calls)
+	}
+}
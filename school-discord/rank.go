@@ -0,0 +1,267 @@
+package schooldiscord
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sahilm/fuzzy"
+)
+
+// Search results are ranked in tiers. A final is placed in the best tier it qualifies for
+const (
+	tierExact  = iota // the pattern is the final's ID, abbreviation or full name
+	tierPrefix        // every word of the pattern starts a word of the final
+	tierFuzzy         // the pattern is a subsequence of the final's name and abbreviation
+	tierTypo          // every word of the pattern is a few typos away from a word of the final
+	tierNone
+)
+
+const minFuzzyLen = 3
+
+type rankedFinal struct {
+	i     int // index in the searched moduleList
+	tier  int
+	score int // lower is better
+}
+
+// normalize lowercases s and spells out umlauts and ß so that "Prüfung" and "Pruefung" are equal
+func normalize(s string) string {
+	b := strings.Builder{}
+	b.Grow(len(s))
+	for _, r := range strings.ToLower(s) {
+		switch r {
+		case 'ä':
+			b.WriteString("ae")
+		case 'ö':
+			b.WriteString("oe")
+		case 'ü':
+			b.WriteString("ue")
+		case 'ß':
+			b.WriteString("ss")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func splitWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// rankedSearch returns at most max finals matching pattern, best matches first.
+// If max is negative, all matches are returned
+func rankedSearch(lst moduleList, pattern string, max int) []modelFinalSearchable {
+	ranked := rank(lst, pattern)
+
+	n := len(ranked)
+	if max >= 0 {
+		n = min(n, max)
+	}
+
+	out := make([]modelFinalSearchable, n)
+	for k := 0; k < n; k++ {
+		out[k] = lst.finals[ranked[k].i]
+	}
+	return out
+}
+
+// rank returns every final of lst matching pattern, sorted by tier and score
+func rank(lst moduleList, pattern string) []rankedFinal {
+	np := normalize(strings.TrimSpace(pattern))
+	pWords := splitWords(np)
+	id, idErr := strconv.Atoi(np)
+
+	ranked := make([]rankedFinal, 0)
+	rest := make([]int, 0, len(lst.finals)) // finals not yet ranked in a better tier
+
+	for i, f := range lst.finals {
+		switch {
+		case idErr == nil && f.id == id,
+			np == lst.abbrs[i],
+			np == lst.names[i]:
+			ranked = append(ranked, rankedFinal{i, tierExact, 0})
+		default:
+			if score, ok := prefixScore(pWords, lst.words[i]); ok {
+				ranked = append(ranked, rankedFinal{i, tierPrefix, score})
+			} else {
+				rest = append(rest, i)
+			}
+		}
+	}
+
+	// fuzzy subsequence matching over whatever did not match a better tier.
+	// Very short patterns are a subsequence of almost everything, so they only match by prefix
+	candidates := moduleList{}
+	candIdx := make([]int, 0, len(rest))
+	tryFuzzy := utf8.RuneCountInString(np) >= minFuzzyLen
+	for _, i := range rest {
+		if tryFuzzy && isSubsequence(np, lst.keys[i]) {
+			candidates.finals = append(candidates.finals, lst.finals[i])
+			candidates.keys = append(candidates.keys, lst.keys[i])
+			candIdx = append(candIdx, i)
+		}
+	}
+	fuzzyMatched := make([]bool, len(lst.finals))
+	for _, m := range fuzzy.FindFrom(np, candidates) {
+		i := candIdx[m.Index]
+		fuzzyMatched[i] = true
+		ranked = append(ranked, rankedFinal{i, tierFuzzy, -m.Score})
+	}
+
+	// typo tolerance for the remaining finals. Words that have to match exactly are checked first,
+	// as they rule out most finals without computing any edit distance
+	typoWords := append([]string(nil), pWords...)
+	sort.SliceStable(typoWords, func(a, b int) bool { return allowedTypos(typoWords[a]) < allowedTypos(typoWords[b]) })
+	for _, i := range rest {
+		if fuzzyMatched[i] {
+			continue
+		}
+		if score, ok := typoScore(typoWords, lst.words[i]); ok {
+			ranked = append(ranked, rankedFinal{i, tierTypo, score})
+		}
+	}
+
+	sort.SliceStable(ranked, func(a, b int) bool {
+		ra, rb := ranked[a], ranked[b]
+		if ra.tier != rb.tier {
+			return ra.tier < rb.tier
+		}
+		if ra.score != rb.score {
+			return ra.score < rb.score
+		}
+		return len(lst.finals[ra.i].name) < len(lst.finals[rb.i].name)
+	})
+
+	return ranked
+}
+
+// prefixScore checks that every pattern word is the prefix of a word of the final.
+// The score prefers finals whose words are matched closely and that have few other words
+func prefixScore(pWords []string, words []string) (int, bool) {
+	if len(pWords) == 0 {
+		return 0, false
+	}
+
+	score := len(words)
+	for _, pw := range pWords {
+		best := -1
+		for _, w := range words {
+			if strings.HasPrefix(w, pw) && (best < 0 || len(w)-len(pw) < best) {
+				best = len(w) - len(pw)
+			}
+		}
+		if best < 0 {
+			return 0, false
+		}
+		score += best
+	}
+	return score, true
+}
+
+// typoScore checks that every pattern word is within a few edits of a word of the final.
+// The score is the total number of edits
+func typoScore(pWords []string, words []string) (int, bool) {
+	if len(pWords) == 0 {
+		return 0, false
+	}
+
+	score := 0
+	for _, pw := range pWords {
+		maxDist := allowedTypos(pw)
+
+		best := -1
+		for _, w := range words {
+			if abs(len(w)-len(pw)) > maxDist {
+				continue
+			}
+			d := 0
+			if maxDist > 0 {
+				d = levenshtein(pw, w, maxDist)
+			} else if pw != w {
+				continue
+			}
+			if d <= maxDist && (best < 0 || d < best) {
+				best = d
+			}
+		}
+		if best < 0 {
+			return 0, false
+		}
+		score += best
+	}
+	return score, true
+}
+
+// short words are too ambiguous to correct and have to match exactly, longer ones may contain more typos
+func allowedTypos(word string) int {
+	n := utf8.RuneCountInString(word)
+	switch {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// levenshtein returns the edit distance between a and b, counted in bytes.
+// Normalized words are almost always ASCII, so this rarely differs from counting runes.
+// Once the distance is sure to be more than max, it stops early and returns max+1
+func levenshtein(a, b string, max int) int {
+	// rows for words of usual length live on the stack
+	var buf [2 * 64]int
+	var prev, curr []int
+	if len(b) < 64 {
+		prev, curr = buf[:len(b)+1], buf[64:64+len(b)+1]
+	} else {
+		prev, curr = make([]int, len(b)+1), make([]int, len(b)+1)
+	}
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		rowMin := i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(min(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+			rowMin = min(rowMin, curr[j])
+		}
+		// the distance never gets smaller than the smallest value of a row
+		if rowMin > max {
+			return max + 1
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// reports whether all runes of pattern appear in key in the same order
+func isSubsequence(pattern string, key string) bool {
+	for _, r := range pattern {
+		i := strings.IndexRune(key, r)
+		if i < 0 {
+			return false
+		}
+		key = key[i+utf8.RuneLen(r):]
+	}
+	return true
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
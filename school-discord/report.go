@@ -0,0 +1,107 @@
+package schooldiscord
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// errorReport is an unexpected error of a command. It is saved under a short reference
+// that the user is shown, so that admins can look it up when the user reports it
+type errorReport struct {
+	ref     string
+	time    time.Time
+	userID  string
+	command string
+	input   string
+	err     string
+	stack   string // the chain of wrapped errors, or where a command panicked
+}
+
+// panicError is a panic of a command, recovered so that it does not take down the bot
+type panicError struct {
+	val   interface{}
+	stack []byte
+}
+
+func (e panicError) Error() string {
+	return fmt.Sprint("panic: ", e.val)
+}
+
+// newReportRef returns a random reference like 3F9A1C02
+func newReportRef() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		// still unique enough to find the error in the logs
+		return fmt.Sprintf("%08X", uint32(time.Now().UnixNano()))
+	}
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// reportError logs an unexpected error of a command with everything known about it, saves it to g
+// and returns the reference to show to the user
+func (s *Service) reportError(g *guild, log *logger, userID string, command string, input string, err error) string {
+	r := errorReport{
+		ref:     newReportRef(),
+		time:    s.clock.Now(),
+		userID:  userID,
+		command: command,
+		input:   input,
+		err:     err.Error(),
+		stack:   errorStack(err),
+	}
+
+	log.Error("Command failed", "ref", r.ref, "command", command, "input", input, "err", err, "stack", r.stack)
+	if serr := insertErrorReport(g, r); serr != nil {
+		log.Error("Could not save error report, it is only in the log", "ref", r.ref, "err", serr)
+	}
+	return r.ref
+}
+
+// errorStack describes where err came from: the stack of a panic,
+// or else the type and message of every error err wraps
+func errorStack(err error) string {
+	var perr panicError
+	if errors.As(err, &perr) {
+		return string(perr.stack)
+	}
+
+	b := strings.Builder{}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		b.WriteString(fmt.Sprintf("%T: %s\n", e, e))
+	}
+	return b.String()
+}
+
+// recoverCmd turns a panic of a command into a panicError in err. Use it as `defer recoverCmd(&err)`
+func recoverCmd(err *error) {
+	if r := recover(); r != nil {
+		*err = panicError{val: r, stack: debug.Stack()}
+	}
+}
+
+func cmdErrorLookup(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	args, err := positional(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	ref := strings.ToUpper(args[0])
+
+	r, err := getErrorReport(t.origin, ref)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		return t.PrintMsg(msgErrorNotFound, ref)
+	}
+
+	return t.Print(tr(t.lang, msgErrorReport, r.ref, r.time.Local().Format("2006-01-02 15:04:05"), mention(r.userID), r.command, r.input),
+		"\n", codeBlockTag, "\n", r.err, "\n\n", r.stack, codeBlockTag)
+}
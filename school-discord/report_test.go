@@ -0,0 +1,47 @@
+package schooldiscord
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestErrorStackOfReturnedError(t *testing.T) {
+	err := fmt.Errorf("loading final: %w", errors.New("connection reset"))
+	stack := errorStack(err)
+
+	want := "*fmt.wrapError: loading final: connection reset\n*errors.errorString: connection reset\n"
+	if stack != want {
+		t.Errorf("got\n%s\nwant\n%s", stack, want)
+	}
+}
+
+func TestErrorStackOfPanic(t *testing.T) {
+	run := func() (err error) {
+		defer recoverCmd(&err)
+		panic("boom")
+	}
+	stack := errorStack(run())
+
+	if !strings.Contains(stack, "TestErrorStackOfPanic") {
+		t.Errorf("stack of the panic is missing:\n%s", stack)
+	}
+}
+
+func TestReportErrorUsesClock(t *testing.T) {
+	var args []driver.Value
+	onSQL(t, func(query string, a []driver.Value) (*fakeRows, error) {
+		if strings.Contains(query, "INSERT INTO error_report") {
+			args = a
+		}
+		return nil, nil
+	})
+	s, g, _ := newTestService(t, newFakeClock())
+
+	ref := s.reportError(g, s.log, testUser, "join", "join 5", errors.New("boom"))
+	if len(args) < 2 || args[0] != ref || args[1] != "2021-03-01 12:00:00" {
+		t.Errorf("report was not saved with the time of the clock, args: %v", args)
+	}
+}
@@ -1,31 +1,163 @@
 package schooldiscord
 
-import "github.com/sahilm/fuzzy"
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
 
-type moduleList []modelFinalSearchable
+// moduleList is a list of finals along with the normalized strings they are searched by
+type moduleList struct {
+	finals []modelFinalSearchable
+	keys   []string   // normalized name and abbreviation, used for fuzzy matching
+	words  [][]string // the words of keys, used for prefix and typo tolerant matching
+	abbrs  []string   // normalized abbreviations
+	names  []string   // normalized names
+}
+
+func newModuleList(finals []modelFinalSearchable) moduleList {
+	lst := moduleList{
+		finals: finals,
+		keys:   make([]string, len(finals)),
+		words:  make([][]string, len(finals)),
+		abbrs:  make([]string, len(finals)),
+		names:  make([]string, len(finals)),
+	}
+	for i, f := range finals {
+		lst.keys[i] = normalize(searchKey(f))
+		lst.words[i] = splitWords(lst.keys[i])
+		lst.abbrs[i] = normalize(f.abbr)
+		lst.names[i] = normalize(f.name)
+	}
+	return lst
+}
+
+// concatenate abbreviation and name for fuzzy searching algo
+func searchKey(f modelFinalSearchable) string {
+	return f.name + " " + f.abbr
+}
 
 func (lst moduleList) Len() int {
-	return len(lst)
+	return len(lst.finals)
 }
 
 func (lst moduleList) String(i int) string {
-	//concatenate abbreviation and name for fuzzy searching algo
-	return lst[i].name + " " + lst[i].abbr
+	return lst.keys[i]
 }
 
-func fuzzySearch(list []modelFinalSearchable, pattern string, max int) []modelFinalSearchable {
-	lst := moduleList(list)
-	matches := fuzzy.FindFrom(pattern, lst)
-	n := min(matches.Len(), max)
-
-	out := make([]modelFinalSearchable, n)
-	for i := 0; i < n; i++ {
-		out[i] = list[matches[i].Index]
+// returns a list containing only the finals matching f.
+// An empty filter returns lst itself, which must not be modified
+func (lst moduleList) filter(f searchFilter) moduleList {
+	if f.empty() {
+		return lst
 	}
+	return lst.subset(func(i int) bool { return f.match(lst.finals[i]) })
+}
 
+// returns a copy of the list containing only the finals for which keep returns true
+func (lst moduleList) subset(keep func(i int) bool) moduleList {
+	out := moduleList{
+		finals: make([]modelFinalSearchable, 0),
+		keys:   make([]string, 0),
+		words:  make([][]string, 0),
+		abbrs:  make([]string, 0),
+		names:  make([]string, 0),
+	}
+	for i := range lst.finals {
+		if keep(i) {
+			out.finals = append(out.finals, lst.finals[i])
+			out.keys = append(out.keys, lst.keys[i])
+			out.words = append(out.words, lst.words[i])
+			out.abbrs = append(out.abbrs, lst.abbrs[i])
+			out.names = append(out.names, lst.names[i])
+		}
+	}
 	return out
 }
 
+// searchFilter narrows down the catalog before searching it. Zero values match everything
+type searchFilter struct {
+	major    string
+	semester int
+	typ      string
+}
+
+// the options that filter the catalog, given as `key:value` or `--key value`
+var filterSpec = argSpec{
+	options: []string{"major", "semester", "type"},
+	aliases: map[string]string{"sem": "semester"},
+}
+
+// filterFrom builds the filter given by the options of a
+func filterFrom(a cmdArgs) (f searchFilter, err error) {
+	f.major = a.opts["major"]
+	f.typ = a.opts["type"]
+	f.semester, err = a.intOpt("semester")
+	return
+}
+
+func (f searchFilter) empty() bool {
+	return f == searchFilter{}
+}
+
+// describe returns the filter in the same `key:value` form it is parsed from
+func (f searchFilter) describe() []string {
+	d := make([]string, 0, 3)
+	if f.major != "" {
+		d = append(d, "major:"+strings.ToUpper(f.major))
+	}
+	if f.semester != 0 {
+		d = append(d, "sem:"+strconv.Itoa(f.semester))
+	}
+	if f.typ != "" {
+		d = append(d, "type:"+strings.ToUpper(f.typ))
+	}
+	return d
+}
+
+func (f searchFilter) match(m modelFinalSearchable) bool {
+	if f.typ != "" && !strings.EqualFold(f.typ, m.typ) {
+		return false
+	}
+
+	// major and semester have to match on the same module
+	for i := range m.majors {
+		if f.major != "" && !strings.EqualFold(f.major, m.majors[i]) {
+			continue
+		}
+		if f.semester != 0 && f.semester != m.semesters[i] {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// returns the semester of a final in the given major, or its lowest semester if major is empty
+func (m modelFinalSearchable) semesterIn(major string) int {
+	sem := 0
+	for i := range m.majors {
+		if major != "" && !strings.EqualFold(major, m.majors[i]) {
+			continue
+		}
+		if sem == 0 || m.semesters[i] < sem {
+			sem = m.semesters[i]
+		}
+	}
+	return sem
+}
+
+// sorts finals by their semester in major, then by name
+func sortBySemester(list []modelFinalSearchable, major string) {
+	sort.SliceStable(list, func(i, j int) bool {
+		si, sj := list[i].semesterIn(major), list[j].semesterIn(major)
+		if si != sj {
+			return si < sj
+		}
+		return list[i].name < list[j].name
+	})
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -0,0 +1,92 @@
+package schooldiscord
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// syntheticCatalog builds a catalog of n finals with names like the ones of a real university
+func syntheticCatalog(n int) moduleList {
+	prefixes := []string{"", "Grundlagen der ", "Einführung in die ", "Angewandte ", "Fortgeschrittene ", "Praktikum "}
+	subjects := []string{"Mathematik", "Informatik", "Programmierung", "Datenbanken", "Betriebssysteme",
+		"Rechnernetze", "Softwaretechnik", "Lineare Algebra", "Analysis", "Statistik", "Physik", "Elektrotechnik",
+		"Künstliche Intelligenz", "Kryptographie", "Compilerbau", "Theoretische Informatik", "Wirtschaftsinformatik",
+		"Betriebswirtschaftslehre", "Rechnungswesen", "Projektmanagement"}
+	majors := []string{"INF", "WIN", "MI", "DI", "ET"}
+
+	finals := make([]modelFinalSearchable, n)
+	for i := range finals {
+		subject := subjects[i%len(subjects)]
+		prefix := prefixes[(i/len(subjects))%len(prefixes)]
+		num := i/(len(subjects)*len(prefixes)) + 1
+		finals[i] = modelFinalSearchable{
+			id:        i + 1,
+			name:      fmt.Sprintf("%s%s %d", prefix, subject, num),
+			abbr:      fmt.Sprintf("%s%d%d", strings.ToUpper(subject[:2]), i%len(prefixes), num),
+			typ:       "K",
+			majors:    []string{majors[i%len(majors)]},
+			semesters: []int{num%7 + 1},
+		}
+	}
+	return newModuleList(finals)
+}
+
+// searches should stay well below a millisecond on a catalog of 2000 modules
+func BenchmarkRankedSearch(b *testing.B) {
+	lst := syntheticCatalog(2000)
+
+	for _, pattern := range []string{"MA01", "mathe", "grundlagen inf", "lineare algebr", "Kryptograhpie", "xyz"} {
+		b.Run(pattern, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rankedSearch(lst, pattern, 10)
+			}
+		})
+	}
+}
+
+func TestRankOrder(t *testing.T) {
+	lst := newModuleList([]modelFinalSearchable{
+		{id: 1, name: "Mathematik 1", abbr: "MA1", typ: "K", majors: []string{"INF"}, semesters: []int{1}},
+		{id: 2, name: "Mathematik 2", abbr: "MA2", typ: "K", majors: []string{"INF"}, semesters: []int{2}},
+		{id: 3, name: "Analysis", abbr: "AN", typ: "K", majors: []string{"INF"}, semesters: []int{1}},
+		{id: 4, name: "Prüfungsvorbereitung", abbr: "PV", typ: "M", majors: []string{"WIN"}, semesters: []int{3}},
+		{id: 5, name: "MA1 Vertiefung", abbr: "MV", typ: "K", majors: []string{"INF"}, semesters: []int{2}},
+	})
+
+	tests := []struct {
+		pattern string
+		first   int // ID of the final ranked first
+		tier    int
+	}{
+		{"MA1", 1, tierExact},
+		{"ma1", 1, tierExact},
+		{"3", 3, tierExact},
+		{"Mathematik 2", 2, tierExact},
+		{"Vertief", 5, tierPrefix},
+		{"Anlysis", 3, tierFuzzy},
+		{"Analyisx", 3, tierTypo},
+		{"Prüfung", 4, tierPrefix},
+		{"Pruefung", 4, tierPrefix},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pattern, func(t *testing.T) {
+			ranked := rank(lst, tc.pattern)
+			if len(ranked) == 0 {
+				t.Fatal("nothing matched")
+			}
+			if got := lst.finals[ranked[0].i].id; got != tc.first || ranked[0].tier != tc.tier {
+				t.Errorf("got final %d in tier %d first, want final %d in tier %d", got, ranked[0].tier, tc.first, tc.tier)
+			}
+		})
+	}
+}
+
+func TestRankNoMatch(t *testing.T) {
+	lst := syntheticCatalog(100)
+	if ranked := rank(lst, "Quantenchromodynamik"); len(ranked) != 0 {
+		t.Errorf("unrelated pattern matched %d finals", len(ranked))
+	}
+}
@@ -3,6 +3,8 @@ package schooldiscord
 import (
 	"errors"
 	"log"
+	"net/http"
+	"sync"
 
 	"github.com/Petrify/simp-core/service"
 	"github.com/bwmarrin/discordgo"
@@ -14,6 +16,9 @@ func init() {
 	service.NewSType(typeName, serviceCtor, false)
 }
 
+// the service started by Start
+var started service.Service
+
 func Start() {
 	serv, err := service.NewService(typeName, 1, "discord")
 	if err != nil {
@@ -21,19 +26,49 @@ func Start() {
 		return
 	}
 	serv.Start()
+	started = serv
+}
+
+// Stop stops the service started by Start
+func Stop() {
+	if started != nil {
+		started.Stop()
+	}
 }
 
 type Service struct {
 	//Service specific Members
-	token   string
-	ds      *discordgo.Session
-	running bool
+	token    string
+	ds       *discordgo.Session
+	running  bool
+	dbSchema string //the service's database schema, known once the service is initialized
 
 	//guild connections
-	guilds map[string]*guild //mapped by guildID
+	guilds  map[string]*guild //mapped by guildID
+	guildMu sync.RWMutex
 
 	//terminal connections
 	terminals map[string]*terminal //Mapped by channelID
+	termMu    sync.Mutex
+
+	//the guilds each user shares with the bot, see sharedGuilds
+	shared   map[string]sharedGuildsEntry
+	sharedMu sync.Mutex
+
+	//leveled logging, written to the service's Log
+	log *logger
+
+	clock clock //the time terminals expire by
+
+	//monitoring
+	metricsAddr string //where to serve metrics and health checks, if set
+	monitor     *http.Server
+	gatewayUp   int32 //1 while the discord gateway is connected
+
+	//shutdown
+	stopping bool
+	stopMu   sync.Mutex
+	work     sync.WaitGroup //commands that have to finish before stopping
 
 	//Abstract service implementation
 	service.AbstractService
@@ -46,6 +81,9 @@ func serviceCtor(id int64, name string, logger *log.Logger) service.Service {
 		running:   false,
 		guilds:    make(map[string]*guild),
 		terminals: make(map[string]*terminal),
+		shared:    make(map[string]sharedGuildsEntry),
+		log:       newLogger(logger),
+		clock:     realClock{},
 
 		AbstractService: *service.NewAbstractService(name, id, logger),
 	}
@@ -61,19 +99,25 @@ func (s *Service) Setup() error {
 //Initializes the service to recieve messages from discord
 func (s *Service) Init() error {
 
+	s.dbSchema = service.Schema(s)
 	err := s.getToken()
 	if err != nil {
 		return err
 	} else if s.token == "" {
 		return errors.New("enter a valid bot-token into your database")
 	}
+	if err = upgradeServiceSchema(s); err != nil {
+		return err
+	}
 	ds, err := discordgo.New("Bot " + s.token)
 	if err != nil {
 		return err
 	}
 	s.ds = ds
+	s.monitorDiscord(ds)
+	s.registerGauges()
 
-	ds.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsDirectMessages | discordgo.IntentsGuildMessages | 1)
+	ds.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsDirectMessages | discordgo.IntentsGuildMessages | discordgo.IntentsGuildVoiceStates | 1)
 
 	s.registerHandlers()
 
@@ -84,18 +128,15 @@ func (s *Service) Start() error {
 
 	err := s.ds.Open()
 	if err != nil {
-		s.Log.Println("error opening discord connection")
+		s.log.Error("Could not open discord connection", "err", err)
 		return err
 	}
 
-	s.Log.Printf("[%d] %s Started Successfully", s.ID(), s.Name())
+	s.running = true
+	if s.metricsAddr != "" {
+		s.startMonitor(s.metricsAddr)
+	}
+	s.log.Info("Started successfully", "service", s.ID(), "name", s.Name())
 
 	return nil
 }
-
-func (s *Service) Stop() {
-	err := s.ds.Close()
-	if err != nil {
-		s.Log.Println("Error Closing discord connection", err)
-	}
-}
@@ -0,0 +1,98 @@
+package schooldiscord
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// how long stopping the service waits for running commands and terminals to finish
+const shutdownTimeout = 30 * time.Second
+
+// beginWork registers a command or other work that has to finish before the service stops.
+// It returns false once the service is stopping, in which case the work must not be started
+func (s *Service) beginWork() bool {
+	s.stopMu.Lock()
+	defer s.stopMu.Unlock()
+
+	if s.stopping {
+		return false
+	}
+	s.work.Add(1)
+	return true
+}
+
+func (s *Service) endWork() {
+	s.work.Done()
+}
+
+func (s *Service) isStopping() bool {
+	s.stopMu.Lock()
+	defer s.stopMu.Unlock()
+	return s.stopping
+}
+
+// Stop stops taking new input, waits for running commands, suspends every open terminal and then disconnects from discord.
+// Terminal sessions are kept, so that they are restored once the service is started again
+func (s *Service) Stop() {
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	s.stopMu.Lock()
+	s.stopping = true
+	s.stopMu.Unlock()
+
+	s.log.Info("Stopping, waiting for running commands")
+	if !waitCtx(ctx, &s.work) {
+		s.log.Warn("Commands still running, stopping anyway", "timeout", shutdownTimeout)
+	}
+
+	terms := s.listTerminals()
+	for _, t := range terms {
+		t.suspend(tr(t.currentLang(), msgBotStopping))
+	}
+	for _, t := range terms {
+		select {
+		case <-t.done:
+		case <-ctx.Done():
+			// the terminal's loop is stuck, clean up without it
+			t.cleanup()
+		}
+	}
+
+	s.guildMu.RLock()
+	for _, g := range s.guilds {
+		g.jobs.stop()
+	}
+	s.guildMu.RUnlock()
+
+	err := s.ds.Close()
+	if err != nil {
+		s.log.Error("Could not close discord connection", "err", err)
+	}
+	s.running = false
+
+	// the monitor reports the service as stopping until the very end
+	mctx, mcancel := context.WithTimeout(context.Background(), time.Second)
+	defer mcancel()
+	s.stopMonitor(mctx)
+
+	s.log.Info("Stopped", "service", s.ID(), "name", s.Name())
+}
+
+// waits for wg until ctx is done. Returns false if wg was not done in time
+func waitCtx(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,471 @@
+package schooldiscord
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	statsTopDefault    = 10
+	statsGrowthDefault = 30 // days
+)
+
+// finalStats is a final with the number of users in it
+type finalStats struct {
+	id        int
+	typ       string
+	name      string
+	abbr      string
+	majors    []string
+	semesters []int //semester of the final in the major of the same index
+	members   int
+}
+
+// groupStats sums up the finals of a major, or of a semester of a major
+type groupStats struct {
+	name        string
+	finals      int
+	enrollments int // memberships in the group's finals, a user in two finals counts twice
+}
+
+// dayStats are the joins and leaves of a day
+type dayStats struct {
+	day    string
+	joins  int
+	leaves int
+	total  int // enrollments at the end of the day
+}
+
+// enrollmentStats is everything the stats commands and reports show about a guild
+type enrollmentStats struct {
+	finals  []finalStats // most members first
+	members int          // users in at least one final
+}
+
+func (s *Service) enrollmentStats(g *guild) (*enrollmentStats, error) {
+	finals, err := getFinalStats(g)
+	if err != nil {
+		return nil, err
+	}
+	members, err := getMemberCount(g)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(finals, func(i, j int) bool { return finals[i].members > finals[j].members })
+	return &enrollmentStats{finals: finals, members: members}, nil
+}
+
+func (st *enrollmentStats) enrollments() int {
+	n := 0
+	for _, f := range st.finals {
+		n += f.members
+	}
+	return n
+}
+
+// top returns the n finals with the most members, leaving out finals without any
+func (st *enrollmentStats) top(n int) []finalStats {
+	top := make([]finalStats, 0, n)
+	for _, f := range st.finals {
+		if len(top) == n || f.members == 0 {
+			break
+		}
+		top = append(top, f)
+	}
+	return top
+}
+
+func (st *enrollmentStats) empty() []finalStats {
+	empty := make([]finalStats, 0)
+	for _, f := range st.finals {
+		if f.members == 0 {
+			empty = append(empty, f)
+		}
+	}
+	sort.Slice(empty, func(i, j int) bool { return empty[i].id < empty[j].id })
+	return empty
+}
+
+func (st *enrollmentStats) byMajor() []groupStats {
+	return st.group(func(major string, _ int) string { return major })
+}
+
+func (st *enrollmentStats) bySemester() []groupStats {
+	return st.group(func(major string, semester int) string { return fmt.Sprintf("%s %d", major, semester) })
+}
+
+// group sums up the finals by the name key gives their major and semester.
+// A final of several modules counts once for every group it is in
+func (st *enrollmentStats) group(key func(major string, semester int) string) []groupStats {
+	groups := make(map[string]*groupStats)
+	names := make([]string, 0)
+	for _, f := range st.finals {
+		seen := make(map[string]bool)
+		for i, major := range f.majors {
+			k := key(major, f.semesters[i])
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+
+			gs, ok := groups[k]
+			if !ok {
+				gs = &groupStats{name: k}
+				groups[k] = gs
+				names = append(names, k)
+			}
+			gs.finals++
+			gs.enrollments += f.members
+		}
+	}
+
+	sort.Strings(names)
+	lst := make([]groupStats, len(names))
+	for i, n := range names {
+		lst[i] = *groups[n]
+	}
+	return lst
+}
+
+// growth returns the joins and leaves of the last days that had any, with the enrollments after each of them.
+// Joins and leaves are taken from the audit log, so days before it was kept have none
+func (s *Service) growth(g *guild, st *enrollmentStats, days int) ([]dayStats, error) {
+	since := time.Now().UTC().AddDate(0, 0, -days+1).Truncate(24 * time.Hour)
+	lst, err := getDailyMemberships(g, since)
+	if err != nil {
+		return nil, err
+	}
+
+	// counted back from today's enrollments
+	total := st.enrollments()
+	for i := len(lst) - 1; i >= 0; i-- {
+		lst[i].total = total
+		total -= lst[i].joins - lst[i].leaves
+	}
+	return lst, nil
+}
+
+func (f finalStats) modules() string {
+	mods := make([]string, len(f.majors))
+	for i := range f.majors {
+		mods[i] = fmt.Sprintf("%s %d", f.majors[i], f.semesters[i])
+	}
+	return strings.Join(mods, ", ")
+}
+
+func (f finalStats) line() string {
+	return fmt.Sprintf("[%4d] | %10d | %s (%s)\n", f.id, f.members, f.name, f.modules())
+}
+
+func (gs groupStats) line() string {
+	return fmt.Sprintf("%-12s | %9d | %d\n", gs.name, gs.finals, gs.enrollments)
+}
+
+func (ds dayStats) line() string {
+	return fmt.Sprintf("%-10s | %9d | %9d | %d\n", ds.day, ds.joins, ds.leaves, ds.total)
+}
+
+func finalLines(finals []finalStats) []string {
+	lines := make([]string, len(finals))
+	for i, f := range finals {
+		lines[i] = f.line()
+	}
+	return lines
+}
+
+func groupLines(groups []groupStats) []string {
+	lines := make([]string, len(groups))
+	for i, gs := range groups {
+		lines[i] = gs.line()
+	}
+	return lines
+}
+
+var statsSpec = argSpec{
+	options: []string{"top", "days"},
+}
+
+func cmdStatsSummary(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	a, err := statsSpec.parse(args)
+	if err != nil {
+		return err
+	}
+	if err = a.require(0, 0); err != nil {
+		return err
+	}
+	n, err := a.intOpt("top")
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		n = statsTopDefault
+	}
+
+	st, err := t.serv.enrollmentStats(t.origin)
+	if err != nil {
+		return err
+	}
+
+	empty := len(st.empty())
+	summary := tr(t.lang, msgStatsSummary, st.members, len(st.finals)-empty, st.enrollments(), empty, len(st.finals))
+	top := st.top(n)
+	if len(top) == 0 {
+		return t.Print(summary)
+	}
+	return t.Print(summary, "\n\n", tr(t.lang, msgStatsTop, len(top)), "\n",
+		codeBlockTag, "\n", tr(t.lang, msgStatsFinalsHeader), strings.Join(finalLines(top), ""), codeBlockTag)
+}
+
+func cmdStatsFinals(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	if _, err := positional(args, 0, 0); err != nil {
+		return err
+	}
+	st, err := t.serv.enrollmentStats(t.origin)
+	if err != nil {
+		return err
+	}
+	return t.PrintPaged(tr(t.lang, msgStatsFinalsList), tr(t.lang, msgStatsFinalsHeader), finalLines(st.finals))
+}
+
+func cmdStatsMajors(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	if _, err := positional(args, 0, 0); err != nil {
+		return err
+	}
+	st, err := t.serv.enrollmentStats(t.origin)
+	if err != nil {
+		return err
+	}
+	return t.PrintPaged(tr(t.lang, msgStatsMajorsList), tr(t.lang, msgStatsMajorsHeader), groupLines(st.byMajor()))
+}
+
+func cmdStatsSemesters(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	if _, err := positional(args, 0, 0); err != nil {
+		return err
+	}
+	st, err := t.serv.enrollmentStats(t.origin)
+	if err != nil {
+		return err
+	}
+	return t.PrintPaged(tr(t.lang, msgStatsSemestersList), tr(t.lang, msgStatsSemestersHeader), groupLines(st.bySemester()))
+}
+
+func cmdStatsEmpty(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	if _, err := positional(args, 0, 0); err != nil {
+		return err
+	}
+	st, err := t.serv.enrollmentStats(t.origin)
+	if err != nil {
+		return err
+	}
+	empty := st.empty()
+	if len(empty) == 0 {
+		return t.PrintMsg(msgStatsNoEmpty)
+	}
+	return t.PrintPaged(tr(t.lang, msgStatsEmptyList), tr(t.lang, msgStatsFinalsHeader), finalLines(empty))
+}
+
+func cmdStatsGrowth(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	a, err := statsSpec.parse(args)
+	if err != nil {
+		return err
+	}
+	if err = a.require(0, 0); err != nil {
+		return err
+	}
+	days, err := a.intOpt("days")
+	if err != nil {
+		return err
+	}
+	if days == 0 {
+		days = statsGrowthDefault
+	}
+
+	st, err := t.serv.enrollmentStats(t.origin)
+	if err != nil {
+		return err
+	}
+	growth, err := t.serv.growth(t.origin, st, days)
+	if err != nil {
+		return err
+	}
+	if len(growth) == 0 {
+		return t.PrintMsg(msgStatsNoGrowth, days)
+	}
+
+	lines := make([]string, len(growth))
+	for i, ds := range growth {
+		lines[i] = ds.line()
+	}
+	return t.PrintPaged(tr(t.lang, msgStatsGrowthList, days), tr(t.lang, msgStatsGrowthHeader), lines)
+}
+
+// reportFile is a file of a report, its name is appended to the name of the report
+type reportFile struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// reportFormats are the formats of the report command, by the name the user gives
+var reportFormats = map[string]func(lang locale, st *enrollmentStats, growth []dayStats, days int) ([]reportFile, error){
+	"csv": reportCSV,
+	"md":  reportMarkdown,
+}
+
+func cmdStatsReport(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	a, err := statsSpec.parse(args)
+	if err != nil {
+		return err
+	}
+	if err = a.require(0, 1); err != nil {
+		return err
+	}
+	format := "md"
+	if len(a.pos) == 1 {
+		format = strings.ToLower(a.pos[0])
+	}
+	build, ok := reportFormats[format]
+	if !ok {
+		return t.PrintMsg(msgStatsUnknownFormat, a.pos[0])
+	}
+	days, err := a.intOpt("days")
+	if err != nil {
+		return err
+	}
+	if days == 0 {
+		days = statsGrowthDefault
+	}
+
+	st, err := t.serv.enrollmentStats(t.origin)
+	if err != nil {
+		return err
+	}
+	growth, err := t.serv.growth(t.origin, st, days)
+	if err != nil {
+		return err
+	}
+	files, err := build(t.lang, st, growth, days)
+	if err != nil {
+		return err
+	}
+
+	prefix := fmt.Sprintf("enrollment-%s-%s", t.origin.id(), time.Now().Format(auditDateFormat))
+	for i := range files {
+		files[i].name = prefix + files[i].name
+	}
+	return t.sendFiles(tr(t.lang, msgStatsReportSent, format), files)
+}
+
+// reportCSV builds a CSV file for every table of the report
+func reportCSV(lang locale, st *enrollmentStats, growth []dayStats, days int) ([]reportFile, error) {
+	finals := [][]string{{"id", "type", "name", "abbreviation", "modules", "members"}}
+	for _, f := range st.finals {
+		finals = append(finals, []string{strconv.Itoa(f.id), f.typ, f.name, f.abbr, f.modules(), strconv.Itoa(f.members)})
+	}
+
+	groups := func(gs []groupStats, name string) [][]string {
+		rows := [][]string{{name, "finals", "enrollments"}}
+		for _, g := range gs {
+			rows = append(rows, []string{g.name, strconv.Itoa(g.finals), strconv.Itoa(g.enrollments)})
+		}
+		return rows
+	}
+
+	dayRows := [][]string{{"day", "joins", "leaves", "enrollments"}}
+	for _, ds := range growth {
+		dayRows = append(dayRows, []string{ds.day, strconv.Itoa(ds.joins), strconv.Itoa(ds.leaves), strconv.Itoa(ds.total)})
+	}
+
+	tables := []struct {
+		name string
+		rows [][]string
+	}{
+		{"-finals.csv", finals},
+		{"-majors.csv", groups(st.byMajor(), "major")},
+		{"-semesters.csv", groups(st.bySemester(), "semester")},
+		{"-growth.csv", dayRows},
+	}
+
+	files := make([]reportFile, len(tables))
+	for i, tb := range tables {
+		buf := bytes.Buffer{}
+		w := csv.NewWriter(&buf)
+		if err := w.WriteAll(tb.rows); err != nil {
+			return nil, err
+		}
+		files[i] = reportFile{name: tb.name, contentType: "text/csv", data: buf.Bytes()}
+	}
+	return files, nil
+}
+
+// reportMarkdown builds a single markdown file with every table of the report
+func reportMarkdown(lang locale, st *enrollmentStats, growth []dayStats, days int) ([]reportFile, error) {
+	b := strings.Builder{}
+	empty := st.empty()
+
+	b.WriteString("# " + tr(lang, msgStatsReportTitle) + "\n\n")
+	b.WriteString(tr(lang, msgStatsSummary, st.members, len(st.finals)-len(empty), st.enrollments(), len(empty), len(st.finals)) + "\n")
+
+	table := func(title string, head []string, rows [][]string) {
+		b.WriteString("\n## " + strings.TrimSuffix(title, ":") + "\n\n")
+		b.WriteString("| " + strings.Join(head, " | ") + " |\n")
+		b.WriteString(strings.Repeat("| --- ", len(head)) + "|\n")
+		for _, r := range rows {
+			for i := range r {
+				r[i] = strings.ReplaceAll(r[i], "|", `\|`)
+			}
+			b.WriteString("| " + strings.Join(r, " | ") + " |\n")
+		}
+	}
+
+	finalRows := func(finals []finalStats) [][]string {
+		rows := make([][]string, len(finals))
+		for i, f := range finals {
+			rows[i] = []string{strconv.Itoa(f.id), f.name, f.modules(), strconv.Itoa(f.members)}
+		}
+		return rows
+	}
+	groupRows := func(groups []groupStats) [][]string {
+		rows := make([][]string, len(groups))
+		for i, gs := range groups {
+			rows[i] = []string{gs.name, strconv.Itoa(gs.finals), strconv.Itoa(gs.enrollments)}
+		}
+		return rows
+	}
+	dayRows := make([][]string, len(growth))
+	for i, ds := range growth {
+		dayRows[i] = []string{ds.day, strconv.Itoa(ds.joins), strconv.Itoa(ds.leaves), strconv.Itoa(ds.total)}
+	}
+
+	finalHead := strings.Split(tr(lang, msgStatsReportFinalCols), ",")
+	groupHead := strings.Split(tr(lang, msgStatsReportGroupCols), ",")
+
+	table(tr(lang, msgStatsFinalsList), finalHead, finalRows(st.finals))
+	table(tr(lang, msgStatsEmptyList), finalHead, finalRows(empty))
+	table(tr(lang, msgStatsMajorsList), groupHead, groupRows(st.byMajor()))
+	table(tr(lang, msgStatsSemestersList), groupHead, groupRows(st.bySemester()))
+	table(tr(lang, msgStatsGrowthList, days), strings.Split(tr(lang, msgStatsReportDayCols), ","), dayRows)
+
+	return []reportFile{{name: ".md", contentType: "text/markdown", data: []byte(b.String())}}, nil
+}
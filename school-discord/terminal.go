@@ -1,37 +1,153 @@
 package schooldiscord
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/Petrify/simp-core/commands"
 	"github.com/bwmarrin/discordgo"
 )
 
 const termTimeout = 600 * time.Second
+const cmdTimeout = 120 * time.Second
 const closeCommand string = "!close"
 const killCommand string = "!kill"
 
+// most finals listed when a name matches several of them
+const maxChoices = 10
+
+// the kinds of terminals, each with its own set of commands
+type termKind int
+
+const (
+	termClass termKind = iota
+	termAdmin
+)
+
+func (k termKind) commands() *commandSet {
+	if k == termAdmin {
+		return adminTermCommands
+	}
+	return classTermCommands
+}
+
+// where a guild opens its terminals
+type termMode string
+
+const (
+	termModeDM      termMode = "dm"      // always in the user's DMs
+	termModeChannel termMode = "channel" // always in a private channel on the guild
+	termModeAuto    termMode = "auto"    // in the user's DMs, or a private channel if the user does not accept DMs
+)
+
+var termModes = []termMode{termModeAuto, termModeDM, termModeChannel}
+
+func parseTermMode(s string) (termMode, bool) {
+	for _, m := range termModes {
+		if strings.EqualFold(s, string(m)) {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+// how long the channel of a private terminal stays around after closing, so the user can read why it closed
+const privateChanLinger = 15 * time.Second
+
+// how far the expiry of a session may move before it is saved again. A restored session
+// may expire this much earlier than it would have, in exchange the session is not saved after every command
+const sessionSaveSlack = time.Minute
+
 type terminal struct {
 	userID string
 	chanID string
-	origin *guild
+	origin *guild //the guild the terminal currently works on
 	serv   *Service
+	lang   locale
+	kind   termKind
+
+	// private terminals run in a channel on the guild that only the user can see, which is deleted with the terminal
+	private bool
 
-	in   chan *discordgo.MessageCreate
-	stop chan string
+	// set when the user shares more than one guild with the bot, or has switched guilds, to tell them apart in the output
+	showGuild bool
+	switchTo  chan *guild
 
-	cmds *commands.Interpreter
+	// mu guards origin, lang and showGuild. Only the terminal's loop changes them, under mu.
+	// The loop may read them as it likes, everyone else has to go through currentGuild and currentLang
+	mu sync.Mutex
+
+	// ctx lives as long as the terminal's session. Cancelling it ends the session
+	// and every command still running in it
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+	reason    string //why the session was closed, set before ctx is cancelled
+	suspended bool   //closed by the service stopping. The session is kept to be restored later
+
+	in       chan *discordgo.MessageCreate //never closed, senders select on done instead
+	done     chan struct{}                 //closed once the terminal has shut down
+	doneOnce sync.Once
+	started  time.Time
+
+	cmds    *commandSet
+	pages   *pager
+	pending func(ctx context.Context) error //action waiting for the user to confirm it
 
 	tMax  time.Duration
-	timer *time.Timer
+	clock clock
+	timer clockTimer
+
+	// sessMu orders saving the session against deleting it, so that a session deleted by cleanup stays deleted
+	sessMu      sync.Mutex
+	sessGone    bool
+	sessExpires time.Time //the expiry last saved
 }
 
-func (s *Service) newTerminal(userID string, cmds *commands.Interpreter, source *guild, timeout time.Duration, message string) error {
+// newTerminal opens a terminal for the user, in their DMs or in a private channel depending on the guild's terminal mode.
+// Notices for the user go to reqChanID, the channel the terminal was requested from.
+// If the user already has a terminal of the same kind open, it is switched over to source instead
+func (s *Service) newTerminal(userID string, kind termKind, source *guild, reqChanID string, timeout time.Duration, greeting msgKey) error {
+
+	if cur, ok := s.findTerminal(userID, kind); ok && !(cur.private && cur.currentGuild() != source) {
+		if cur.currentGuild() != source && !cur.private && cur.switchGuild(source) {
+			return nil
+		}
+		cur.PrintMsg(msgTerminalExists, closeCommand, killCommand)
+		return errTerminalExists
+	}
+
+	mode := source.terminalMode()
+	if mode == termModeChannel {
+		return s.newPrivateTerminal(userID, kind, source, reqChanID, timeout, greeting)
+	}
+
+	err := s.newDMTerminal(userID, kind, source, timeout, greeting)
+	if err == nil || err == errTerminalExists {
+		return err
+	}
+
+	if mode == termModeAuto {
+		source.log.Info("Could not open terminal in DMs, falling back to a private channel", "user", userID, "err", err)
+		return s.newPrivateTerminal(userID, kind, source, reqChanID, timeout, greeting)
+	}
+
+	if reqChanID != "" && isDMsClosed(err) {
+		s.ds.ChannelMessageSend(reqChanID, tr(source.userLocale(userID), msgDMsClosed, mention(userID)))
+	}
+	return err
+}
+
+var errTerminalExists = errors.New("terminal already exists")
+
+// opens a terminal on the user's DMs
+func (s *Service) newDMTerminal(userID string, kind termKind, source *guild, timeout time.Duration, greeting msgKey) error {
 
 	//get DM channel for user
 	channel, err := s.ds.UserChannelCreate(userID)
@@ -39,264 +155,1013 @@ func (s *Service) newTerminal(userID string, cmds *commands.Interpreter, source
 		return err
 	}
 
-	_, ok := s.terminals[channel.ID]
-	if ok {
-		s.ds.ChannelMessageSend(channel.ID, fmt.Sprintf("There is already an active terminal on this channel. Please use %s to close this terminal before opening a new one. If the terminal is stuck, use %s (not recommended)", closeCommand, killCommand))
-		return errors.New("terminal already exists")
+	term := s.makeTerminal(userID, channel.ID, kind, source, timeout)
+
+	if !s.addTerminal(term) {
+		term.cancel()
+		s.ds.ChannelMessageSend(channel.ID, tr(term.lang, msgTerminalExists, closeCommand, killCommand))
+		return errTerminalExists
 	}
 
+	// users that do not accept DMs only fail once something is sent
+	if err = term.start(timeout, greeting); err != nil {
+		term.discard()
+		return err
+	}
+	return nil
+}
+
+// opens a terminal in a new channel on source that only the user and the bot can see.
+// A private thread or ephemeral interaction replies would be lighter, but this version of discordgo supports neither
+func (s *Service) newPrivateTerminal(userID string, kind termKind, source *guild, reqChanID string, timeout time.Duration, greeting msgKey) error {
+
+	parentID := ""
+	if reqChanID != "" {
+		if c, err := s.ds.State.Channel(reqChanID); err == nil {
+			parentID = c.ParentID
+		}
+	}
+
+	c, err := s.makePrivateChan(source, "terminal-"+source.memberName(userID), parentID, userID)
+	if err != nil {
+		return err
+	}
+
+	term := s.makeTerminal(userID, c.ID, kind, source, timeout)
+	term.private = true
+	s.addTerminal(term) // a new channel never has a terminal yet
+
+	if err = term.start(timeout, greeting); err != nil {
+		term.discard()
+		s.ds.ChannelDelete(c.ID)
+		return err
+	}
+	term.PrintMsg(msgPrivateTerminal)
+
+	if reqChanID != "" {
+		s.ds.ChannelMessageSend(reqChanID, tr(term.lang, msgTerminalOpened, mention(userID), "<#"+c.ID+">"))
+	}
+	return nil
+}
+
+// reports whether err is discord refusing to deliver a DM to a user
+func isDMsClosed(err error) bool {
+	var rerr *discordgo.RESTError
+	return errors.As(err, &rerr) && rerr.Message != nil &&
+		rerr.Message.Code == discordgo.ErrCodeCannotSendMessagesToThisUser
+}
+
+func mention(userID string) string {
+	return "<@" + userID + ">"
+}
+
+func (s *Service) makeTerminal(userID string, chanID string, kind termKind, source *guild, timeout time.Duration) *terminal {
+
+	ctx, cancel := context.WithCancel(context.Background())
 	term := &terminal{
 		serv:   s,
 		userID: userID,
-		chanID: channel.ID,
+		chanID: chanID,
 		origin: source,
+		lang:   source.userLocale(userID),
+		kind:   kind,
 
-		in:   make(chan *discordgo.MessageCreate),
-		stop: make(chan string, 1),
+		switchTo: make(chan *guild, 1),
 
-		cmds: cmds,
+		ctx:    ctx,
+		cancel: cancel,
+
+		in:      make(chan *discordgo.MessageCreate),
+		done:    make(chan struct{}),
+		started: s.clock.Now(),
+
+		cmds: kind.commands(),
 
 		tMax:  timeout,
-		timer: time.NewTimer(timeout),
+		clock: s.clock,
+		timer: s.clock.NewTimer(timeout),
 	}
 
 	term.timer.Stop() //so that the timer only truly starts when the terminal's loop begins
-	s.terminals[channel.ID] = term
-	term.Print(message)
-	go term.loop() //start terminal read loop
+	return term
+}
+
+// start greets the user and runs the terminal until it is closed or idle for longer than expiresIn.
+// If the greeting can not be sent, the terminal is not started and the error is returned
+func (t *terminal) start(expiresIn time.Duration, greeting msgKey) error {
+	// in DMs nothing else tells the user which guild the terminal works on.
+	// A private terminal's channel is on its guild, which can not be switched
+	if !t.private && len(t.serv.sharedGuilds(t.userID)) > 1 {
+		t.mu.Lock()
+		t.showGuild = true
+		t.mu.Unlock()
+	}
+
+	if err := t.PrintMsg(greeting); err != nil {
+		return err
+	}
+	t.persist(expiresIn)
+	go t.loop(expiresIn) //start terminal read loop
 	return nil
 }
 
+// discard removes a terminal that never started
+func (t *terminal) discard() {
+	t.cancel()
+	t.rmTerm()
+	t.forget()
+}
+
+// persist saves the terminal's session so that it can be restored after a restart.
+// Once the terminal has closed, its session is not saved anymore
+func (t *terminal) persist(expiresIn time.Duration) {
+	t.sessMu.Lock()
+	defer t.sessMu.Unlock()
+	if t.sessGone {
+		return
+	}
+
+	expires := t.clock.Now().Add(expiresIn)
+	err := saveSession(t.serv, modelSession{
+		chanID:  t.chanID,
+		userID:  t.userID,
+		guildID: t.currentGuild().id(),
+		kind:    t.kind,
+		private: t.private,
+		expires: expires,
+	})
+	if err != nil {
+		t.log().Error("Could not save terminal session", "err", err)
+		return
+	}
+	t.sessExpires = expires
+}
+
+// touch saves the later expiry of the session after input, unless the saved one is still close enough
+func (t *terminal) touch() {
+	// closed while the command ran, the session is gone or kept as it was suspended
+	if t.ctx.Err() != nil {
+		return
+	}
+
+	t.sessMu.Lock()
+	moved := t.clock.Now().Add(t.tMax).Sub(t.sessExpires)
+	t.sessMu.Unlock()
+	if moved >= sessionSaveSlack {
+		t.persist(t.tMax)
+	}
+}
+
+// forget deletes the terminal's session and keeps it from being saved again
+func (t *terminal) forget() {
+	t.sessMu.Lock()
+	defer t.sessMu.Unlock()
+	t.sessGone = true
+	deleteSession(t.serv, t.chanID)
+}
+
+// restoreTerminals reopens the sessions on g that were open when the bot was last stopped
+func (s *Service) restoreTerminals(g *guild) {
+
+	sessions, err := getSessions(s, g.id())
+	if err != nil {
+		g.log.Error("Could not load terminal sessions", "err", err)
+		return
+	}
+
+	for _, ms := range sessions {
+		remaining := ms.expires.Sub(s.clock.Now())
+		if remaining <= 0 {
+			deleteSession(s, ms.chanID)
+			continue
+		}
+
+		term := s.makeTerminal(ms.userID, ms.chanID, ms.kind, g, termTimeout)
+		term.private = ms.private
+		if !s.addTerminal(term) {
+			term.cancel()
+			continue
+		}
+		if err = term.start(remaining, msgSessionRestored); err != nil {
+			term.log().Error("Could not restore terminal session", "err", err)
+			term.discard()
+		}
+	}
+}
+
+// registers t with the service, unless there already is a terminal on its channel
+func (s *Service) addTerminal(t *terminal) bool {
+	s.termMu.Lock()
+	defer s.termMu.Unlock()
+
+	if _, ok := s.terminals[t.chanID]; ok {
+		return false
+	}
+	s.terminals[t.chanID] = t
+	return true
+}
+
+// returns all open terminals, oldest first
+func (s *Service) listTerminals() []*terminal {
+	s.termMu.Lock()
+	defer s.termMu.Unlock()
+
+	lst := make([]*terminal, 0, len(s.terminals))
+	for _, t := range s.terminals {
+		lst = append(lst, t)
+	}
+	sort.Slice(lst, func(i, j int) bool { return lst[i].started.Before(lst[j].started) })
+	return lst
+}
+
+// returns the open terminals that work on g, oldest first. Admins may only see and kill those of their own guild
+func (s *Service) guildTerminals(g *guild) []*terminal {
+	lst := make([]*terminal, 0)
+	for _, t := range s.listTerminals() {
+		if t.currentGuild() == g {
+			lst = append(lst, t)
+		}
+	}
+	return lst
+}
+
+// returns the user's open terminal of the given kind, wherever it runs
+func (s *Service) findTerminal(userID string, kind termKind) (*terminal, bool) {
+	for _, t := range s.listTerminals() {
+		if t.userID == userID && t.kind == kind {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Service) getTerminal(chanID string) (*terminal, bool) {
+	s.termMu.Lock()
+	defer s.termMu.Unlock()
+
+	t, ok := s.terminals[chanID]
+	return t, ok
+}
+
 //Removes terminal from the undelying service
 func (t *terminal) rmTerm() {
-	_, ok := t.serv.terminals[t.chanID]
-	if ok {
+	t.serv.termMu.Lock()
+	defer t.serv.termMu.Unlock()
+
+	if cur, ok := t.serv.terminals[t.chanID]; ok && cur == t {
 		delete(t.serv.terminals, t.chanID)
 	}
 }
 
-// closes a terminal
+// closes a terminal. Only the first reason given is kept
 func (t *terminal) close(reason string) {
-	t.stop <- reason
+	t.closeOnce.Do(func() {
+		t.reason = reason
+		t.cancel()
+	})
 }
 
-func (t *terminal) cleanup(reason string) {
-	t.timer.Stop()
-	t.rmTerm()
-	close(t.in)
-	t.Print(fmt.Sprintf("Terminal is now closed.\nReason: %s\n", reason))
+// suspend closes a terminal without ending its session, so that it is restored when the service starts again
+func (t *terminal) suspend(reason string) {
+	t.closeOnce.Do(func() {
+		t.suspended = true
+		t.reason = reason
+		t.cancel()
+	})
+}
+
+// kill shuts a terminal down immediately, without waiting for its loop to exit.
+// Use this for terminals stuck in a command, the loop cleans up after itself once it is unblocked
+func (t *terminal) kill(reason string) {
+	t.close(reason)
+	t.cleanup()
+}
+
+// switchGuild asks the terminal's loop to work on g once it is done with what it is doing.
+// It does not wait for the loop, and returns false if the terminal has already shut down
+func (t *terminal) switchGuild(g *guild) bool {
+	for {
+		select {
+		case <-t.done:
+			return false
+		default:
+		}
+
+		select {
+		case t.switchTo <- g:
+			return true
+		default:
+			// the loop has not taken the last switch yet, this one replaces it
+			select {
+			case <-t.switchTo:
+			default:
+			}
+		}
+	}
+}
+
+// must only be called from the terminal's loop
+func (t *terminal) setOrigin(g *guild) {
+	lang := g.userLocale(t.userID)
+	t.mu.Lock()
+	t.origin = g
+	t.lang = lang
+	t.showGuild = true
+	t.mu.Unlock()
+	t.pages = nil
+	t.pending = nil
+	t.persist(t.tMax)
+	t.PrintMsg(msgGuildSwitched, g.dgGuild.Name)
+}
+
+// must only be called from the terminal's loop
+func (t *terminal) setLang(l locale) {
+	t.mu.Lock()
+	t.lang = l
+	t.mu.Unlock()
+}
+
+// currentGuild returns the guild the terminal works on. Unlike origin, it may be called from outside the terminal's loop
+func (t *terminal) currentGuild() *guild {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.origin
+}
+
+// currentLang returns the locale the terminal talks in. Unlike lang, it may be called from outside the terminal's loop
+func (t *terminal) currentLang() locale {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.lang
+}
+
+// deliver hands a message to the terminal's loop.
+// It returns false if the terminal has already shut down
+func (t *terminal) deliver(m *discordgo.MessageCreate) bool {
+	select {
+	case t.in <- m:
+		return true
+	case <-t.done:
+		return false
+	}
+}
+
+func (t *terminal) cleanup() {
+	t.doneOnce.Do(func() {
+		t.timer.Stop()
+		t.rmTerm()
+		close(t.done)
+		// kill cleans up on another goroutine than the loop's
+		t.Print(tr(t.currentLang(), msgTerminalClosed, t.reason))
+		if t.suspended {
+			// the session is kept as it was when the terminal was suspended
+			t.sessMu.Lock()
+			t.sessGone = true
+			t.sessMu.Unlock()
+			return
+		}
+
+		t.forget()
+		if t.private {
+			time.AfterFunc(privateChanLinger, func() {
+				if _, err := t.serv.ds.ChannelDelete(t.chanID); err != nil {
+					t.log().Warn("Could not delete terminal channel", "err", err)
+				}
+			})
+		}
+	})
+}
+
+// sendFiles sends files to the terminal's channel, with text as the message they are attached to
+func (t *terminal) sendFiles(text string, files []reportFile) error {
+	return retry(context.Background(), func() error {
+		// readers are used up by a failed attempt, so every attempt gets new ones
+		send := &discordgo.MessageSend{Content: text}
+		for _, f := range files {
+			send.Files = append(send.Files, &discordgo.File{Name: f.name, ContentType: f.contentType, Reader: bytes.NewReader(f.data)})
+		}
+		_, err := t.serv.ds.ChannelMessageSendComplex(t.chanID, send)
+		return err
+	})
 }
 
 func (t *terminal) Print(text ...interface{}) (err error) {
-	_, err = t.serv.ds.ChannelMessageSend(t.chanID, fmt.Sprint(text...))
-	return
+	return t.send(fmt.Sprint(text...))
 }
 
 func (t *terminal) Printf(format string, a ...interface{}) (err error) {
-	_, err = t.serv.ds.ChannelMessageSend(t.chanID, fmt.Sprintf(format, a...))
+	return t.send(fmt.Sprintf(format, a...))
+}
+
+// sends text to the terminal's channel, split into as many messages as needed
+func (t *terminal) send(text string) (err error) {
+	t.mu.Lock()
+	if t.showGuild {
+		text = fmt.Sprintf("**[%s]** %s", t.origin.dgGuild.Name, text)
+	}
+	t.mu.Unlock()
+	for _, chunk := range splitMessage(text, msgLimit) {
+		err = retry(context.Background(), func() error {
+			_, err := t.serv.ds.ChannelMessageSend(t.chanID, chunk)
+			return err
+		})
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
+// prints lines as a table that the user can page through with next and prev
+func (t *terminal) PrintPaged(title string, head string, lines []string) error {
+	t.pages = newPager(title, head, lines, pageSize)
+	return t.printPage()
+}
+
+func (t *terminal) printPage() error {
+	if t.pages.len() == 1 {
+		return t.Print(t.pages.page())
+	}
+	return t.Print(t.pages.page(), "\n", tr(t.lang, msgPageFooter, t.pages.cur+1, t.pages.len()))
+}
+
+// prints the text for key in the terminal's locale
+func (t *terminal) PrintMsg(key msgKey, a ...interface{}) (err error) {
+	return t.Print(tr(t.lang, key, a...))
+}
+
 func (t *terminal) Read() (text string, ok bool) {
-	msg, ok := <-t.in
-	if !ok {
-		return "", ok
+	select {
+	case msg := <-t.in:
+		return msg.Message.Content, true
+	case <-t.ctx.Done():
+		return "", false
 	}
-	text = msg.Message.Content
-	return
 }
 
-func (t *terminal) loop() {
-	var inp *discordgo.MessageCreate
+func (t *terminal) loop(expiresIn time.Duration) {
+	defer t.cleanup()
+
 	// reset the timer to start it
-	t.timer.Reset(t.tMax)
+	t.timer.Reset(expiresIn)
 
 	for {
-		//force checking timer & stop signal before allowing checking input
-		select {
-		case <-t.timer.C:
-			t.cleanup("The session has expired")
+		//closing takes precedence over any input that is waiting
+		if t.ctx.Err() != nil {
 			return
+		}
 
-		case reason := <-t.stop:
-			t.cleanup(reason)
+		select {
+		case <-t.ctx.Done():
 			return
 
-		default:
+		case <-t.timer.C():
+			mTermTimeouts.inc()
+			t.close(tr(t.lang, msgSessionExpired))
 
-			//Check st
-			select {
-			case reason := <-t.stop:
-				t.cleanup(reason)
-				return
-
-			case <-t.timer.C:
-				t.cleanup("The session has expired")
-				return
-
-			case inp = <-t.in:
-				t.timer.Stop() //so that the session does not expire during command execution
-				err := t.cmds.Run(context.TODO(), strings.ToLower(inp.Message.Content), t, inp)
-				if err != nil {
-					t.handleCmdErr(err)
+		case g := <-t.switchTo:
+			t.setOrigin(g)
+
+		case inp := <-t.in:
+			//so that the session does not expire during command execution
+			if !t.timer.Stop() {
+				// drain a timeout that fired at the same time as the input arrived
+				select {
+				case <-t.timer.C():
+				default:
 				}
 			}
+			t.exec(inp)
+			t.touch()
+			t.timer.Reset(t.tMax)
+		}
+	}
+}
+
+// log returns a logger that adds the guild, user and channel of the terminal to every line
+func (t *terminal) log() *logger {
+	return t.currentGuild().log.With("user", t.userID, "terminal", t.chanID)
+}
+
+// exec runs a single input of the user. Commands are cancelled when the session closes or after cmdTimeout
+func (t *terminal) exec(inp *discordgo.MessageCreate) {
+	if !t.serv.beginWork() {
+		t.PrintMsg(msgShuttingDown)
+		return
+	}
+	defer t.serv.endWork()
+
+	ctx, cancel := context.WithTimeout(t.ctx, cmdTimeout)
+	defer cancel()
+
+	if t.pending != nil {
+		t.answerPending(ctx, inp.Message.Content)
+		return
+	}
+
+	err := t.cmds.Run(ctx, inp.Message.Content, &TerminalCommandContext{term: t, msg: inp})
+	if err != nil {
+		t.handleCmdErr(t.cmds.name(inp.Message.Content), inp.Message.Content, err)
+	}
+}
+
+// confirm holds back action until the user answers the next input with yes
+func (t *terminal) confirm(action func(ctx context.Context) error) {
+	t.pending = action
+}
+
+func (t *terminal) answerPending(ctx context.Context, answer string) {
+	action := t.pending
+	t.pending = nil
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes", "j", "ja":
+		if err := t.runPending(ctx, action); err != nil {
+			t.handleCmdErr("confirm", answer, err)
 		}
-		t.timer.Reset(t.tMax)
+	default:
+		t.PrintMsg(msgCancelled)
 	}
 }
 
-func (t *terminal) handleCmdErr(err error) {
-	switch err.(type) {
-	case commands.InvalidCommandError:
-		t.Print("Unknown command")
-	case commands.InvalidArgsError:
-		t.Print("That Command does not support those arguments") //TODO: NYI
-	case commands.ExecutionError:
-		t.serv.Log.Print("Encountered error while executing a command: ", err)
-		t.Print(
-			`Uh Oh! An error occurred while executing your command! 
-			If this issue persists please file a an error report`)
+// runs an action the user confirmed. Like commands, its panics are recovered
+func (t *terminal) runPending(ctx context.Context, action func(ctx context.Context) error) (err error) {
+	defer recoverCmd(&err)
+	return action(ctx)
+}
+
+func (t *terminal) handleCmdErr(cmd string, input string, err error) {
+	switch e := err.(type) {
+	case unknownCommandError:
+		t.PrintMsg(msgUnknownCommand)
+	case usageError:
+		t.Print(e.message(t.lang, ""))
+	case argError:
+		t.Print(e.message(t.lang))
 	default:
-		t.serv.Log.Print("Encountered error while executing a command: ", err)
-		t.Print("An unknown error has occured")
+		if key, args, ok := userMessage(err); ok {
+			if isOutage(err) {
+				t.log().Warn("Command failed, a service is unavailable", "command", cmd, "err", err)
+			}
+			t.PrintMsg(key, args...)
+			return
+		}
+		ref := t.serv.reportError(t.origin, t.log(), t.userID, cmd, input, err)
+		t.PrintMsg(msgExecutionError, ref)
 	}
 }
 
-func verifyTerm(ext []interface{}) (*terminal, *discordgo.MessageCreate) {
-	e0 := ext[0].(*terminal)
-	e1 := ext[1].(*discordgo.MessageCreate)
-	return e0, e1
+// search takes the catalog filters and --all to show every match instead of the best ones
+var searchSpec = argSpec{
+	flags:   []string{"all"},
+	options: filterSpec.options,
+	aliases: filterSpec.aliases,
 }
 
-func cmdSearch(ctx context.Context, args []string, ext ...interface{}) error {
-	t, _ := verifyTerm(ext)
+func cmdSearch(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
 
-	var key string
-	var max int
-	if len(args) == 0 {
-		t.Print("Bitte Suchbegriff eingeben")
-		return nil
+	a, err := searchSpec.parse(args)
+	if err != nil {
+		return err
+	}
+	filter, err := filterFrom(a)
+	if err != nil {
+		return err
+	}
+
+	max := 10
+	if a.flags["all"] {
+		max = -1
+	}
+
+	if len(a.pos) == 0 && filter.empty() {
+		return errUsage
 	}
 
-	max = 10
-	key = strings.Join(args, " ")
+	key := strings.Join(a.pos, " ")
 
-	ctlg, err := t.serv.getModuleCatalog(t.origin)
+	ctlg, err := t.serv.catalog(t.origin, filter)
 	if err != nil {
 		return err
 	}
 
-	matches := fuzzySearch(ctlg, key, max)
+	var matches []modelFinalSearchable
+	if key == "" {
+		// filters only, show everything they let through
+		key = strings.Join(filter.describe(), " ")
+		matches = ctlg.finals
+		sortBySemester(matches, filter.major)
+	} else {
+		matches = rankedSearch(ctlg, key, max)
+	}
+
 	if len(matches) == 0 {
-		return t.Print(fmt.Sprintf("Keine Ergebnisse Für **%s**", key))
+		return t.PrintMsg(msgNoResults, key)
 	}
-	resp := strings.Builder{}
-	resp.WriteString(fmt.Sprintf("Suchergebnisse für **%s**\n", key))
-	resp.WriteString("```[-ID-] | Prüfungsfach (Studiengänge)\n")
-	for _, m := range matches {
-		resp.WriteString(fmt.Sprintf("[%4d] | %s (%s)\n", m.id, m.name, strings.Join(m.majors, ", ")))
+	lines := make([]string, len(matches))
+	for i, m := range matches {
+		lines[i] = fmt.Sprintf("[%4d] | %s (%s)\n", m.id, m.name, strings.Join(m.majors, ", "))
 	}
-	resp.WriteString("```")
-	t.Print(resp.String())
-	return nil
+	return t.PrintPaged(tr(t.lang, msgSearchResults, key), tr(t.lang, msgSearchTableHeader), lines)
 
 }
 
-func cmdJoin(ctx context.Context, args []string, ext ...interface{}) error {
-	t, m := verifyTerm(ext)
+func cmdBrowse(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
 
 	if len(args) == 0 {
-		t.Print("Bitte ID eingeben")
-		return nil
+		majors, err := getMajors(t.origin)
+		if err != nil {
+			return err
+		}
+
+		lines := make([]string, len(majors))
+		for i, m := range majors {
+			lines[i] = fmt.Sprintf("%-6s | %s\n", strings.ToUpper(m.abbr), m.name)
+		}
+		return t.PrintPaged(tr(t.lang, msgBrowseMajors), "\n", lines)
 	}
 
-	id, err := strconv.Atoi(args[0])
-	if err != nil {
-		t.Print(args[0], " ist keine ID")
-		return nil
+	if len(args) > 2 {
+		return errUsage
+	}
+	filter := searchFilter{major: args[0]}
+	if len(args) > 1 {
+		n, err := parseCount(args[1])
+		if err != nil {
+			return err
+		}
+		filter.semester = n
 	}
 
-	mf, err := t.serv.getFinal(int64(id), t.origin)
+	ctlg, err := t.serv.catalog(t.origin, filter)
 	if err != nil {
 		return err
 	}
 
-	if mf == nil {
-		t.Print(args[0], " wurde nicht gefunden")
-		return nil
+	matches := ctlg.finals
+	key := strings.Join(filter.describe(), " ")
+	if len(matches) == 0 {
+		return t.PrintMsg(msgNoResults, key)
 	}
+	sortBySemester(matches, filter.major)
 
-	err = t.serv.joinFinal(t.origin, mf, m.Author.ID)
+	lines := make([]string, len(matches))
+	for i, m := range matches {
+		lines[i] = fmt.Sprintf("[%4d] | %3d | %-3s | %s\n", m.id, m.semesterIn(filter.major), strings.ToUpper(m.typ), m.name)
+	}
+	return t.PrintPaged(tr(t.lang, msgSearchResults, key), tr(t.lang, msgBrowseTableHeader), lines)
+}
+
+// reports whether join or leave arguments name more than one final
+func isBulk(args []string) bool {
+	if strings.EqualFold(args[0], "all") {
+		return true
+	}
+	if len(args) == 1 {
+		return false
+	}
+	for _, a := range args {
+		if _, err := parseID(a); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupFinal finds the final given by an ID, an abbreviation or a name.
+// If several finals match equally well, the user is shown them to pick from and nil is returned
+func (t *terminal) lookupFinal(args []string) (*modelFinal, error) {
+
+	if id, err := parseID(args[0]); err == nil && len(args) == 1 {
+		mf, err := t.serv.getFinal(id, t.origin)
+		if err == nil && mf == nil {
+			return nil, finalNotFoundError{args[0]}
+		}
+		return mf, err
+	}
+
+	query := strings.Join(args, " ")
+
+	ctlg, err := t.serv.catalog(t.origin, searchFilter{})
 	if err != nil {
-		switch err.(type) {
-		case AlreadyJoinedError:
-			t.Print("Du bist dieser Prüfung bereits beigetreten")
-			return nil
+		return nil, err
+	}
+
+	ranked := rank(ctlg, query)
+	if len(ranked) == 0 {
+		return nil, finalNotFoundError{query}
+	}
+
+	// a final is only picked if it matches better than all others
+	if len(ranked) > 1 && ranked[0].tier == ranked[1].tier {
+		lines := make([]string, 0)
+		for _, r := range ranked {
+			if r.tier != ranked[0].tier || len(lines) == maxChoices {
+				break
+			}
+			m := ctlg.finals[r.i]
+			lines = append(lines, fmt.Sprintf("[%4d] | %s (%s)\n", m.id, m.name, strings.Join(m.majors, ", ")))
 		}
+		return nil, t.PrintPaged(tr(t.lang, msgAmbiguousFinal, query), tr(t.lang, msgSearchTableHeader), lines)
+	}
+
+	mf, err := t.serv.getFinal(int64(ctlg.finals[ranked[0].i].id), t.origin)
+	if err == nil && mf == nil {
+		return nil, finalNotFoundError{query}
+	}
+	return mf, err
+}
+
+func cmdJoin(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	a, err := filterSpec.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(a.pos) == 0 {
+		return errUsage
+	}
+
+	if isBulk(a.pos) {
+		return t.bulkJoinLeave(ctx, a, c.Author().ID, false)
+	}
+	if len(a.opts) > 0 {
+		return errUsage // filters only work with all
+	}
+
+	mf, err := t.lookupFinal(a.pos)
+	if err != nil || mf == nil {
+		return err
+	}
+
+	err = t.serv.joinFinal(ctx, t.origin, mf, c.Author().ID, t.PrintMsg)
+	if err != nil {
 		return err
 	}
 
-	t.Printf("**%s** wurde erfolgreich zu Deinen Prüfungen hinzugefügt.", mf.name)
+	t.PrintMsg(msgJoined, mf.name)
 	return nil
 }
 
-func cmdLeave(ctx context.Context, args []string, ext ...interface{}) error {
-	t, m := verifyTerm(ext)
+func cmdLeave(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	a, err := filterSpec.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(a.pos) == 0 {
+		return errUsage
+	}
+
+	if isBulk(a.pos) {
+		return t.bulkJoinLeave(ctx, a, c.Author().ID, true)
+	}
+	if len(a.opts) > 0 {
+		return errUsage // filters only work with all
+	}
+
+	mf, err := t.lookupFinal(a.pos)
+	if err != nil || mf == nil {
+		return err
+	}
+
+	err = t.serv.leaveFinal(ctx, t.origin, mf, c.Author().ID, t.PrintMsg)
+	if err != nil {
+		return err
+	}
 
+	t.PrintMsg(msgLeft, mf.name)
+	return nil
+}
+
+func cmdList(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	lst, err := getUserFinals(t.origin, c.Author().ID)
+	if err != nil {
+		return err
+	}
+
+	if len(lst) == 0 {
+		return t.PrintMsg(msgNoFinals)
+	}
+	lines := make([]string, len(lst))
+	for i, m := range lst {
+		lines[i] = fmt.Sprintf("[%4d] | %s \n", m.id, m.name)
+	}
+	return t.PrintPaged(tr(t.lang, msgYourFinals), "\n", lines)
+}
+
+func cmdNextPage(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	if t.pages == nil || !t.pages.next() {
+		return t.PrintMsg(msgNoMorePages)
+	}
+	return t.printPage()
+}
+
+func cmdPrevPage(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	if t.pages == nil || !t.pages.prev() {
+		return t.PrintMsg(msgNoMorePages)
+	}
+	return t.printPage()
+}
+
+func cmdLanguage(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	args, err := positional(args, 0, 1)
+	if err != nil {
+		return err
+	}
 	if len(args) == 0 {
-		t.Print("Bitte ID eingeben")
-		return nil
+		return t.PrintMsg(msgLanguageCurrent, t.lang, localeList())
+	}
+
+	l, ok := parseLocale(args[0])
+	if !ok {
+		return t.PrintMsg(msgLanguageUnknown, args[0], localeList())
 	}
 
-	id, err := strconv.Atoi(args[0])
+	err = setUserLocale(t.origin, c.Author().ID, l)
 	if err != nil {
-		t.Print(args[0], " ist keine ID >:(")
-		return nil
+		return err
 	}
 
-	mf, err := t.serv.getFinal(int64(id), t.origin)
+	t.setLang(l)
+	return t.PrintMsg(msgLanguageSet, l)
+}
+
+func cmdDefaultLanguage(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+	g := t.origin
+
+	args, err := positional(args, 0, 1)
 	if err != nil {
 		return err
 	}
+	if len(args) == 0 {
+		return t.PrintMsg(msgLanguageCurrent, g.language(), localeList())
+	}
 
-	if mf == nil {
-		t.Print(args[0], " wurde nicht gefunden")
-		return nil
+	l, ok := parseLocale(args[0])
+	if !ok {
+		return t.PrintMsg(msgLanguageUnknown, args[0], localeList())
 	}
 
-	err = t.serv.leaveFinal(t.origin, mf, m.Author.ID)
+	err = t.serv.setGuildOption(g, "language", string(g.language()), string(l), c.Author().ID)
 	if err != nil {
-		switch err.(type) {
-		case NotJoinedError:
-			t.Printf("Man kann keine Prüfung varlassen der Man nie beigetreten bist!")
-		}
 		return err
 	}
 
-	t.Printf("**%s** wurde erfolgreich von Deinen Prüfungen gelöscht.", mf.name)
-	return nil
+	g.setLanguage(l)
+	t.serv.refreshAllFinalInfo(g)
+	return t.PrintMsg(msgLanguageDefaultSet, l)
+}
+
+func cmdReindex(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	t.origin.index.invalidate()
+	if err := t.origin.index.load(t.serv, t.origin); err != nil {
+		return err
+	}
+	t.serv.audit(t.origin, auditEvent{actor: c.Author().ID, action: auditReindex, target: "index"})
+	// reindexing is how admins apply changes to the catalog
+	t.serv.refreshAllFinalInfo(t.origin)
+
+	return t.PrintMsg(msgReindexed)
+}
+
+func cmdTerminals(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	terms := t.serv.guildTerminals(t.origin)
+	if len(terms) == 0 {
+		return t.PrintMsg(msgNoTerminals)
+	}
+
+	lines := make([]string, len(terms))
+	for i, term := range terms {
+		lines[i] = fmt.Sprintf("%-20s | %-20s | %-8s | %s\n",
+			term.userID, term.chanID, t.clock.Now().Sub(term.started).Truncate(time.Second), term.currentGuild().dgGuild.Name)
+	}
+	return t.PrintPaged(tr(t.lang, msgTerminalList), tr(t.lang, msgTerminalTableHeader), lines)
 }
 
-func cmdList(ctx context.Context, args []string, ext ...interface{}) error {
-	t, m := verifyTerm(ext)
+func cmdKillTerminal(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
 
-	lst, err := getUserFinals(t.origin, m.Author.ID)
+	args, err := positional(args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseSnowflake(args[0])
 	if err != nil {
 		return err
 	}
 
-	if len(lst) == 0 {
-		return t.Print("Du hast noch keine Prüfungen")
+	// the ID may be either a user's or a terminal channel's
+	n := 0
+	target := "channel:" + id
+	for _, term := range t.serv.guildTerminals(t.origin) {
+		if term.userID == id {
+			target = userTarget(id)
+		}
+		if term.userID == id || term.chanID == id {
+			term.kill(tr(term.currentLang(), msgKilledByAdmin))
+			n++
+		}
 	}
-	resp := strings.Builder{}
-	resp.WriteString("Deine Prüfungen:")
-	resp.WriteString("```")
-	for _, m := range lst {
-		resp.WriteString(fmt.Sprintf("[%4d] | %s \n", m.id, m.name))
+
+	if n == 0 {
+		return t.PrintMsg(msgNoSuchTerminal, id)
 	}
-	resp.WriteString("```")
-	t.Print(resp.String())
+	t.serv.audit(t.origin, auditEvent{actor: c.Author().ID, action: auditKill, target: target, after: strconv.Itoa(n)})
+	return t.PrintMsg(msgTerminalsKilled, n)
+}
+
+func cmdGuild(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+
+	shared := t.serv.sharedGuilds(t.userID)
+	if t.private {
+		// a private terminal's channel belongs to its guild, showing it other guilds would leak them to that guild's admins
+		shared = []*guild{t.origin}
+	}
+
+	if len(args) == 0 {
+		lines := make([]string, len(shared))
+		for i, g := range shared {
+			mark := ""
+			if g == t.origin {
+				mark = " *"
+			}
+			lines[i] = fmt.Sprintf("[%2d] | %s%s\n", i+1, g.dgGuild.Name, mark)
+		}
+		return t.PrintPaged(tr(t.lang, msgGuildList), "\n", lines)
+	}
+
+	g := pickGuild(shared, strings.Join(args, " "))
+	if g == nil {
+		return t.PrintMsg(msgInvalidGuild, strings.Join(args, " "))
+	}
+	if g != t.origin {
+		t.setOrigin(g)
+	}
+	return nil
+}
 
+// pickGuild finds a guild by its number in a list shown to the user, its ID or its name
+func pickGuild(guilds []*guild, arg string) *guild {
+	if n, err := strconv.Atoi(arg); err == nil && n >= 1 && n <= len(guilds) {
+		return guilds[n-1]
+	}
+	for _, g := range guilds {
+		if g.id() == arg || strings.EqualFold(g.dgGuild.Name, arg) {
+			return g
+		}
+	}
 	return nil
 }
+
+func cmdTerminalMode(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+	g := t.origin
+
+	args, err := positional(args, 0, 1)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return t.PrintMsg(msgTermModeCurrent, g.terminalMode(), termModeList())
+	}
+
+	mode, ok := parseTermMode(args[0])
+	if !ok {
+		return t.PrintMsg(msgTermModeUnknown, args[0], termModeList())
+	}
+
+	err = t.serv.setGuildOption(g, "terminal_mode", string(g.terminalMode()), string(mode), c.Author().ID)
+	if err != nil {
+		return err
+	}
+
+	g.setTerminalMode(mode)
+	return t.PrintMsg(msgTermModeSet, mode)
+}
+
+func termModeList() string {
+	names := make([]string, len(termModes))
+	for i, m := range termModes {
+		names[i] = string(m)
+	}
+	return strings.Join(names, ", ")
+}
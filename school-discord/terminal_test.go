@@ -0,0 +1,521 @@
+package schooldiscord
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	testUser = "1"
+	testChan = "200"
+)
+
+// startTestTerminal starts a class terminal that expires after timeout and waits for its timer to run
+func startTestTerminal(t *testing.T, s *Service, g *guild, clk *fakeClock, timeout time.Duration) *terminal {
+	t.Helper()
+
+	term := s.makeTerminal(testUser, testChan, termClass, g, timeout)
+	if !s.addTerminal(term) {
+		t.Fatal("channel already has a terminal")
+	}
+	if err := term.start(timeout, msgClassGreeting); err != nil {
+		t.Fatal(err)
+	}
+	clk.waitArmed(t, timeout)
+	return term
+}
+
+func waitClosed(t *testing.T, term *terminal) {
+	t.Helper()
+	select {
+	case <-term.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("terminal did not close")
+	}
+}
+
+func isClosed(term *terminal) bool {
+	select {
+	case <-term.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestTerminalExpires(t *testing.T) {
+	onSQL(t, nil)
+	clk := newFakeClock()
+	s, g, dc := newTestService(t, clk)
+	term := startTestTerminal(t, s, g, clk, time.Minute)
+
+	clk.advance(59 * time.Second)
+	if isClosed(term) {
+		t.Fatal("terminal closed before it expired")
+	}
+
+	clk.advance(time.Second)
+	waitClosed(t, term)
+
+	if !dc.sentContaining(tr(localeDE, msgSessionExpired)) {
+		t.Errorf("user was not told that the session expired, sent: %q", dc.messages())
+	}
+	if _, ok := s.getTerminal(testChan); ok {
+		t.Error("expired terminal is still registered")
+	}
+}
+
+func TestTerminalInputResetsTimeout(t *testing.T) {
+	onSQL(t, nil)
+	clk := newFakeClock()
+	s, g, dc := newTestService(t, clk)
+	term := startTestTerminal(t, s, g, clk, time.Minute)
+
+	clk.advance(50 * time.Second)
+	if !term.deliver(userMsg(testUser, testChan, "nosuchcommand")) {
+		t.Fatal("terminal closed before the input was delivered")
+	}
+	clk.waitArmed(t, time.Minute)
+
+	// a minute after starting, but not after the input
+	clk.advance(50 * time.Second)
+	if isClosed(term) {
+		t.Fatal("terminal expired although it got input")
+	}
+	if !dc.sentContaining(tr(localeDE, msgUnknownCommand)) {
+		t.Errorf("input was not answered, sent: %q", dc.messages())
+	}
+
+	clk.advance(10 * time.Second)
+	waitClosed(t, term)
+}
+
+func TestTerminalCloseDuringExec(t *testing.T) {
+	onSQL(t, nil)
+	clk := newFakeClock()
+	s, g, dc := newTestService(t, clk)
+
+	running := make(chan struct{})
+	cancelled := make(chan struct{})
+	cmds := newCommandSet(scopeTerminal)
+	cmds.add("block", func(ctx context.Context, args []string, c *TerminalCommandContext) error {
+		close(running)
+		<-ctx.Done()
+		close(cancelled)
+		return nil
+	}, msgHelpPing, "")
+
+	term := s.makeTerminal(testUser, testChan, termClass, g, time.Minute)
+	term.cmds = cmds
+	s.addTerminal(term)
+	if err := term.start(time.Minute, msgClassGreeting); err != nil {
+		t.Fatal(err)
+	}
+
+	term.deliver(userMsg(testUser, testChan, "block"))
+	<-running
+	term.close("closed by test")
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("running command was not cancelled")
+	}
+	waitClosed(t, term)
+
+	if !dc.sentContaining("closed by test") {
+		t.Errorf("user was not told why the terminal closed, sent: %q", dc.messages())
+	}
+	if term.deliver(userMsg(testUser, testChan, "help")) {
+		t.Error("closed terminal still takes input")
+	}
+}
+
+func TestTerminalKillDuringStuckCommand(t *testing.T) {
+	onSQL(t, nil)
+	clk := newFakeClock()
+	s, g, dc := newTestService(t, clk)
+
+	running := make(chan struct{})
+	release := make(chan struct{})
+	cmds := newCommandSet(scopeTerminal)
+	cmds.add("stuck", func(ctx context.Context, args []string, c *TerminalCommandContext) error {
+		close(running)
+		<-release // ignores its context, like a command stuck in a call
+		c.term.setLang(localeEN)
+		return nil
+	}, msgHelpPing, "")
+
+	term := s.makeTerminal(testUser, testChan, termClass, g, time.Minute)
+	term.cmds = cmds
+	s.addTerminal(term)
+	if err := term.start(time.Minute, msgClassGreeting); err != nil {
+		t.Fatal(err)
+	}
+
+	term.deliver(userMsg(testUser, testChan, "stuck"))
+	<-running
+	s.handleTerminalMsg(userMsg(testUser, testChan, killCommand))
+
+	// killing does not wait for the command
+	if !isClosed(term) {
+		t.Fatal("terminal was not closed by kill")
+	}
+	if _, ok := s.getTerminal(testChan); ok {
+		t.Error("killed terminal is still registered")
+	}
+	if !dc.sentContaining(tr(localeDE, msgKilledByUser)) {
+		t.Errorf("user was not told that the terminal was killed, sent: %q", dc.messages())
+	}
+	close(release)
+}
+
+// addTestGuild adds another guild that every user is a member of
+func addTestGuild(s *Service, id string, name string) *guild {
+	g := &guild{
+		cmds:     guildCommands,
+		index:    newSearchIndex(),
+		jobs:     newJobQueue(s.log),
+		log:      s.log,
+		lang:     localeDE,
+		termMode: termModeDM,
+		dgGuild:  &discordgo.Guild{ID: id, Name: name},
+		ds:       s.ds,
+		dbSchema: "test" + id,
+	}
+	s.guilds[id] = g
+	return g
+}
+
+func TestTerminalNamesGuildOfUserInSeveralGuilds(t *testing.T) {
+	onSQL(t, nil)
+	clk := newFakeClock()
+	s, g, dc := newTestService(t, clk)
+	other := addTestGuild(s, "101", "Andere Hochschule")
+	t.Cleanup(other.jobs.stop)
+
+	term := startTestTerminal(t, s, g, clk, time.Minute)
+	defer term.close("test over")
+
+	greeting := "**[Testserver]** " + tr(localeDE, msgClassGreeting)
+	if msgs := dc.messages(); len(msgs) == 0 || msgs[0] != greeting {
+		t.Errorf("greeting does not name the guild, sent: %q", msgs)
+	}
+}
+
+func TestTerminalSwitchDoesNotWaitForCommand(t *testing.T) {
+	onSQL(t, nil)
+	clk := newFakeClock()
+	s, g, dc := newTestService(t, clk)
+	other := addTestGuild(s, "101", "Andere Hochschule")
+	t.Cleanup(other.jobs.stop)
+
+	running := make(chan struct{})
+	release := make(chan struct{})
+	cmds := newCommandSet(scopeTerminal)
+	cmds.add("slow", func(ctx context.Context, args []string, c *TerminalCommandContext) error {
+		close(running)
+		<-release
+		return nil
+	}, msgHelpPing, "")
+
+	term := s.makeTerminal(testUser, testChan, termClass, g, time.Minute)
+	term.cmds = cmds
+	s.addTerminal(term)
+	if err := term.start(time.Minute, msgClassGreeting); err != nil {
+		t.Fatal(err)
+	}
+	defer term.close("test over")
+
+	term.deliver(userMsg(testUser, testChan, "slow"))
+	<-running
+
+	switched := make(chan bool)
+	go func() { switched <- term.switchGuild(other) }()
+	select {
+	case ok := <-switched:
+		if !ok {
+			t.Fatal("switch was refused by an open terminal")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("switching guilds waited for the running command")
+	}
+
+	close(release)
+	clk.waitArmed(t, time.Minute)
+	for i := 0; i < 100 && term.currentGuild() != other; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if term.currentGuild() != other {
+		t.Fatal("terminal did not switch once the command was done")
+	}
+	if !dc.sentContaining(tr(localeDE, msgGuildSwitched, "Andere Hochschule")) {
+		t.Errorf("user was not told about the switch, sent: %q", dc.messages())
+	}
+}
+
+// sessionSaves counts how often the terminal session was saved
+func sessionSaves() int {
+	n := 0
+	for _, stmt := range sqlStatements() {
+		if strings.Contains(stmt, "REPLACE INTO terminal_session") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestTerminalSessionSavedOnlyWhenExpiryMoved(t *testing.T) {
+	onSQL(t, nil)
+	clk := newFakeClock()
+	s, g, _ := newTestService(t, clk)
+	term := startTestTerminal(t, s, g, clk, 10*time.Minute)
+	defer term.close("test over")
+
+	if n := sessionSaves(); n != 1 {
+		t.Fatalf("session saved %d times when starting, want 1", n)
+	}
+
+	term.deliver(userMsg(testUser, testChan, "nosuchcommand"))
+	clk.waitArmed(t, 10*time.Minute)
+	if n := sessionSaves(); n != 1 {
+		t.Errorf("session saved again although its expiry did not move, %d saves", n)
+	}
+
+	clk.advance(2 * time.Minute)
+	term.deliver(userMsg(testUser, testChan, "nosuchcommand"))
+	clk.waitArmed(t, 10*time.Minute)
+	if n := sessionSaves(); n != 2 {
+		t.Errorf("session not saved after its expiry moved, %d saves", n)
+	}
+}
+
+func TestKilledTerminalSessionStaysDeleted(t *testing.T) {
+	onSQL(t, nil)
+	clk := newFakeClock()
+	s, g, _ := newTestService(t, clk)
+
+	running := make(chan struct{})
+	release := make(chan struct{})
+	cmds := newCommandSet(scopeTerminal)
+	cmds.add("stuck", func(ctx context.Context, args []string, c *TerminalCommandContext) error {
+		close(running)
+		<-release
+		return nil
+	}, msgHelpPing, "")
+
+	term := s.makeTerminal(testUser, testChan, termClass, g, time.Minute)
+	term.cmds = cmds
+	s.addTerminal(term)
+	if err := term.start(time.Minute, msgClassGreeting); err != nil {
+		t.Fatal(err)
+	}
+	clk.waitArmed(t, time.Minute)
+
+	// the command finishes a while later, when the expiry would have to be saved again
+	term.deliver(userMsg(testUser, testChan, "stuck"))
+	<-running
+	term.kill("killed by test")
+	clk.advance(5 * time.Minute)
+	saves := sessionSaves()
+	close(release)
+	clk.waitArmed(t, time.Minute)
+
+	if n := sessionSaves(); n != saves {
+		t.Error("session of a killed terminal was saved again")
+	}
+	deleted := false
+	for _, stmt := range sqlStatements() {
+		deleted = deleted || strings.Contains(stmt, "DELETE FROM terminal_session")
+	}
+	if !deleted {
+		t.Error("session of a killed terminal was not deleted")
+	}
+}
+
+// joinLeaveSQL answers the statements of joining or leaving finals 5 and 6, which have a channel and role.
+// joined is whether the user is in every final
+func joinLeaveSQL(finalExists bool, joined bool) sqlHandler {
+	finals := map[int64]string{5: "Mathematik 1", 6: "Mathematik 2"}
+	return func(query string, args []driver.Value) (*fakeRows, error) {
+		switch {
+		case strings.Contains(query, "WHERE final.idfinal = ?"):
+			id, _ := args[0].(int64)
+			if !finalExists || finals[id] == "" {
+				return nil, nil
+			}
+			return rows(nil, []interface{}{id, finals[id], fmt.Sprintf("MA%d", id-4), fmt.Sprint(300 + id), fmt.Sprint(400 + id)}), nil
+		case strings.Contains(query, "SELECT idfinal, final.type"):
+			if !finalExists {
+				return nil, nil
+			}
+			return rows(nil,
+				[]interface{}{int64(5), "K", finals[5], "MA1", "INF", int64(1)},
+				[]interface{}{int64(6), "K", finals[6], "MA2", "INF", int64(2)}), nil
+		case strings.Contains(query, "SELECT user.iduser"):
+			return rows(nil, []interface{}{testUser, nil}), nil
+		case strings.Contains(query, "SELECT iduser FROM ref_user_has_final"):
+			if !joined {
+				return nil, nil
+			}
+			return rows(nil, []interface{}{testUser}), nil
+		}
+		return nil, nil
+	}
+}
+
+func TestJoinLeaveMessages(t *testing.T) {
+	forbidden := func(req *http.Request) (int, string) {
+		if strings.Contains(req.URL.Path, "/roles/") {
+			return http.StatusForbidden, `{"code":50013,"message":"Missing Permissions"}`
+		}
+		return 0, ""
+	}
+	dbDown := func(query string, args []driver.Value) (*fakeRows, error) {
+		if strings.Contains(query, "idfinal") {
+			return nil, driver.ErrBadConn
+		}
+		return nil, nil
+	}
+	invalidArgs := strings.SplitN(tr(localeDE, msgInvalidArgs, "", "", ""), ".", 2)[0]
+
+	tests := []struct {
+		name    string
+		inputs  []string
+		sql     sqlHandler
+		respond func(req *http.Request) (int, string)
+		stopped bool     // whether the guild's job queue is stopped, like while discord is unreachable
+		want    []string // texts that have to be sent
+		replies int      // how many messages the inputs are answered with, if it matters
+	}{
+		{name: "join", inputs: []string{"join 5"}, sql: joinLeaveSQL(true, false),
+			want: []string{tr(localeDE, msgJoined, "Mathematik 1")}},
+		{name: "join by abbreviation", inputs: []string{"join MA2"}, sql: joinLeaveSQL(true, false),
+			want: []string{tr(localeDE, msgJoined, "Mathematik 2")}},
+		{name: "join without arguments", inputs: []string{"join"}, sql: joinLeaveSQL(true, false),
+			want: []string{invalidArgs}, replies: 1},
+		{name: "join ambiguous name", inputs: []string{"join Mathematik"}, sql: joinLeaveSQL(true, false),
+			want: []string{tr(localeDE, msgAmbiguousFinal, "Mathematik")}, replies: 1},
+		{name: "join several IDs", inputs: []string{"join 5 6 7", "ja"}, sql: joinLeaveSQL(true, false),
+			want: []string{tr(localeDE, msgBulkConfirmJoin, 2), tr(localeDE, msgBulkSummary), tr(localeDE, msgBulkJoined), tr(localeDE, msgBulkNotFound)}},
+		{name: "join not found", inputs: []string{"join 5"}, sql: joinLeaveSQL(false, false),
+			want: []string{tr(localeDE, msgFinalNotFound, "5")}, replies: 1},
+		{name: "join already joined", inputs: []string{"join 5"}, sql: joinLeaveSQL(true, true),
+			want: []string{tr(localeDE, msgAlreadyJoined)}, replies: 1},
+		{name: "join permission denied", inputs: []string{"join 5"}, sql: joinLeaveSQL(true, false), respond: forbidden,
+			want: []string{tr(localeDE, msgPermissionDenied)}, replies: 1},
+		{name: "join discord unavailable", inputs: []string{"join 5"}, sql: joinLeaveSQL(true, false), stopped: true,
+			want: []string{tr(localeDE, msgDiscordUnavailable)}, replies: 1},
+		{name: "join database unavailable", inputs: []string{"join 5"}, sql: dbDown,
+			want: []string{tr(localeDE, msgDBUnavailable)}, replies: 1},
+		{name: "leave", inputs: []string{"leave 5"}, sql: joinLeaveSQL(true, true),
+			want: []string{tr(localeDE, msgLeft, "Mathematik 1")}},
+		{name: "leave without arguments", inputs: []string{"leave"}, sql: joinLeaveSQL(true, true),
+			want: []string{invalidArgs}, replies: 1},
+		{name: "leave several IDs", inputs: []string{"leave 5 6", "ja"}, sql: joinLeaveSQL(true, true),
+			want: []string{tr(localeDE, msgBulkConfirmLeave, 2), tr(localeDE, msgBulkSummary), tr(localeDE, msgBulkLeft)}},
+		{name: "leave not found", inputs: []string{"leave 5"}, sql: joinLeaveSQL(false, false),
+			want: []string{tr(localeDE, msgFinalNotFound, "5")}, replies: 1},
+		{name: "leave not joined", inputs: []string{"leave 5"}, sql: joinLeaveSQL(true, false),
+			want: []string{tr(localeDE, msgNotJoined)}, replies: 1},
+		{name: "leave permission denied", inputs: []string{"leave 5"}, sql: joinLeaveSQL(true, true), respond: forbidden,
+			want: []string{tr(localeDE, msgPermissionDenied)}, replies: 1},
+		{name: "leave discord unavailable", inputs: []string{"leave 5"}, sql: joinLeaveSQL(true, true), stopped: true,
+			want: []string{tr(localeDE, msgDiscordUnavailable)}, replies: 1},
+		{name: "leave database unavailable", inputs: []string{"leave 5"}, sql: dbDown,
+			want: []string{tr(localeDE, msgDBUnavailable)}, replies: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			onSQL(t, tc.sql)
+			clk := newFakeClock()
+			s, g, dc := newTestService(t, clk)
+			dc.respond = tc.respond
+			if tc.stopped {
+				g.jobs.stop()
+			}
+			term := startTestTerminal(t, s, g, clk, time.Minute)
+			defer term.close("test over")
+			greeted := len(dc.messages())
+
+			for _, in := range tc.inputs {
+				term.deliver(userMsg(testUser, testChan, in))
+				clk.waitArmed(t, time.Minute)
+			}
+
+			for _, want := range tc.want {
+				if !dc.sentContaining(want) {
+					t.Errorf("want %q, sent: %q", want, dc.messages())
+				}
+			}
+			if n := len(dc.messages()) - greeted; tc.replies > 0 && n != tc.replies {
+				t.Errorf("answered with %d messages, want %d: %q", n, tc.replies, dc.messages()[greeted:])
+			}
+		})
+	}
+}
+
+func TestAdminOnlySeesTerminalsOfOwnGuild(t *testing.T) {
+	onSQL(t, nil)
+	clk := newFakeClock()
+	s, g, dc := newTestService(t, clk)
+	other := addTestGuild(s, "101", "Andere Hochschule")
+	t.Cleanup(other.jobs.stop)
+
+	open := func(userID string, chanID string, g *guild) *terminal {
+		term := s.makeTerminal(userID, chanID, termClass, g, time.Hour)
+		s.addTerminal(term)
+		if err := term.start(time.Hour, msgClassGreeting); err != nil {
+			t.Fatal(err)
+		}
+		clk.waitArmed(t, time.Hour)
+		return term
+	}
+	own := open("2", "201", g)
+	defer own.close("test over")
+	foreign := open("3", "202", other)
+	defer foreign.close("test over")
+
+	admin := s.makeTerminal(testUser, testChan, termAdmin, g, time.Minute)
+	s.addTerminal(admin)
+	if err := admin.start(time.Minute, msgAdminGreeting); err != nil {
+		t.Fatal(err)
+	}
+	defer admin.close("test over")
+	clk.waitArmed(t, time.Minute)
+
+	admin.deliver(userMsg(testUser, testChan, "terminals"))
+	clk.waitArmed(t, time.Minute)
+	msgs := dc.messages()
+	list := msgs[len(msgs)-1]
+	if !strings.Contains(list, "201") {
+		t.Errorf("terminal of the own guild is not listed: %q", list)
+	}
+	if strings.Contains(list, "202") || strings.Contains(list, "Andere Hochschule") {
+		t.Errorf("terminal of another guild is listed: %q", list)
+	}
+
+	admin.deliver(userMsg(testUser, testChan, "kill 3"))
+	clk.waitArmed(t, time.Minute)
+	if isClosed(foreign) {
+		t.Error("admin killed a terminal of another guild")
+	}
+	if !dc.sentContaining(tr(localeDE, msgNoSuchTerminal, "3")) {
+		t.Errorf("admin was not told that there is no such terminal, sent: %q", dc.messages())
+	}
+
+	admin.deliver(userMsg(testUser, testChan, "kill 2"))
+	waitClosed(t, own)
+	clk.waitArmed(t, time.Minute)
+	if !dc.sentContaining(tr(localeDE, msgTerminalsKilled, 1)) {
+		t.Errorf("admin was not told about the killed terminal, sent: %q", dc.messages())
+	}
+}
@@ -0,0 +1,326 @@
+package schooldiscord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// how long a voice room made with the voice command may stay empty before it is deleted
+const voiceRoomIdle = 5 * time.Minute
+
+// the most voice rooms a final can have at once
+const maxVoiceRooms = 3
+
+// Discord threads would suit study groups as well, but this version of discordgo can not create them
+
+// setupFinalVoice creates the voice channel of a final, unless it already has one.
+// Like the final's text channel, only members with its role can see it
+func (s *Service) setupFinalVoice(g *guild, finalID int, name string, roleID string) (created bool, err error) {
+	existing, err := getVoiceChannels(g, finalID, false)
+	if err != nil || len(existing) > 0 {
+		return false, err
+	}
+
+	c, err := s.makeVoiceChan(g, name, g.finalsCatID, roleID)
+	if err != nil {
+		return false, err
+	}
+
+	if err = insertVoiceChannel(g, c.ID, finalID, false); err != nil {
+		if _, derr := s.ds.ChannelDelete(c.ID); derr != nil {
+			return false, derr
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// provisionFinalVoice gives every final that has a channel its voice channel and returns how many were created
+func (s *Service) provisionFinalVoice(ctx context.Context, g *guild) (created int, err error) {
+	ids, err := getChannelFinalIDs(g)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		final, err := s.getFinal(int64(id), g)
+		if err != nil {
+			return created, err
+		}
+		if final == nil || final.channelID == "" {
+			continue
+		}
+
+		var ok bool
+		err = g.jobs.do(ctx, fmt.Sprintf("final-voice:%d", id), nil, func(int) (err error) {
+			ok, err = s.setupFinalVoice(g, id, fmt.Sprintf("%s [%d]", final.name, final.id), final.roleID)
+			return
+		})
+		if err != nil {
+			return created, err
+		}
+		if ok {
+			created++
+		}
+	}
+	return created, nil
+}
+
+// removeFinalVoice deletes the voice channel of every final and returns how many were deleted.
+// Voice rooms made with the voice command are left until they are empty
+func (s *Service) removeFinalVoice(ctx context.Context, g *guild) (removed int, err error) {
+	ids, err := getVoiceChannels(g, 0, false)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if err = s.deleteVoiceChan(ctx, g, id); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// deleteVoiceChan deletes a voice channel on discord, if it is still there, and forgets it
+func (s *Service) deleteVoiceChan(ctx context.Context, g *guild, chanID string) error {
+	err := g.jobs.do(ctx, "voice-delete:"+chanID, nil, func(int) error {
+		_, err := s.ds.ChannelDelete(chanID)
+		if errors.Is(discordError(err), errNotFound) {
+			return nil // somebody deleted it already
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return deleteVoiceChannel(g, chanID)
+}
+
+// voiceOccupants returns how many users are in a voice channel, as far as the state knows
+func (s *Service) voiceOccupants(g *guild, chanID string) (n int) {
+	dg, err := s.ds.State.Guild(g.id())
+	if err != nil {
+		return 0
+	}
+
+	s.ds.State.RLock()
+	defer s.ds.State.RUnlock()
+	for _, vs := range dg.VoiceStates {
+		if vs.ChannelID == chanID {
+			n++
+		}
+	}
+	return n
+}
+
+// addVoiceRoom starts watching a voice room, so that it is deleted once it stayed empty for voiceRoomIdle
+func (s *Service) addVoiceRoom(g *guild, chanID string) {
+	g.voiceMu.Lock()
+	g.voiceRooms[chanID] = nil
+	g.voiceMu.Unlock()
+
+	s.checkVoiceRooms(g)
+}
+
+// restoreVoiceRooms watches the voice rooms that were left when the bot was stopped
+func (s *Service) restoreVoiceRooms(g *guild) {
+	ids, err := getVoiceChannels(g, 0, true)
+	if err != nil {
+		g.log.Warn("Could not load voice rooms, they are not deleted when empty", "err", err)
+		return
+	}
+	for _, id := range ids {
+		s.addVoiceRoom(g, id)
+	}
+}
+
+// checkVoiceRooms starts the timer of every voice room that became empty and stops it for those that are used again
+func (s *Service) checkVoiceRooms(g *guild) {
+	g.voiceMu.Lock()
+	defer g.voiceMu.Unlock()
+
+	for id, timer := range g.voiceRooms {
+		if s.voiceOccupants(g, id) > 0 {
+			if timer != nil {
+				timer.Stop()
+				g.voiceRooms[id] = nil
+			}
+			continue
+		}
+		if timer == nil {
+			id := id
+			var t *time.Timer
+			t = time.AfterFunc(voiceRoomIdle, func() {
+				s.expireVoiceRoom(g, id, t)
+			})
+			g.voiceRooms[id] = t
+		}
+	}
+}
+
+// expireVoiceRoom deletes a voice room whose timer ran out, unless the timer was replaced or somebody joined since
+func (s *Service) expireVoiceRoom(g *guild, chanID string, timer *time.Timer) {
+	if !s.beginWork() {
+		return
+	}
+	defer s.endWork()
+
+	g.voiceMu.Lock()
+	if g.voiceRooms[chanID] != timer || s.voiceOccupants(g, chanID) > 0 {
+		g.voiceMu.Unlock()
+		return
+	}
+	delete(g.voiceRooms, chanID)
+	g.voiceMu.Unlock()
+
+	// on failure the room is left to be deleted the next time the guild is loaded
+	if err := s.deleteVoiceChan(context.Background(), g, chanID); err != nil {
+		g.log.Warn("Could not delete empty voice room", "channel", chanID, "err", err)
+		return
+	}
+	g.log.Info("Deleted empty voice room", "channel", chanID)
+}
+
+// voiceRoomName returns the name of a new voice room of final, with the lowest number none of its rooms has
+func (s *Service) voiceRoomName(g *guild, final *modelFinal, rooms []string) string {
+	taken := make(map[string]bool, len(rooms))
+	for _, id := range rooms {
+		ch, err := s.ds.State.Channel(id)
+		if err != nil {
+			ch, err = s.ds.Channel(id)
+		}
+		if err == nil {
+			taken[ch.Name] = true
+		}
+	}
+
+	lang := g.language()
+	for n := 1; ; n++ {
+		if name := tr(lang, msgVoiceRoomName, final.name, n); !taken[name] {
+			return name
+		}
+	}
+}
+
+// -----COMMAND FUNCTIONS--------
+
+func cmdVoice(ctx context.Context, args []string, c *GuildCommandContext) error {
+	g := c.g
+
+	finalID, err := getChannelFinalID(g, c.msg.ChannelID)
+	if err != nil {
+		return err
+	}
+	if finalID == 0 {
+		return c.ReplyMsg(msgVoiceNotFinalChannel)
+	}
+	final, err := c.serv.getFinal(int64(finalID), g)
+	if err != nil {
+		return err
+	}
+	if final == nil {
+		return finalNotFoundError{fmt.Sprint(finalID)}
+	}
+
+	// counting the rooms and creating one happen in the same job, so that two users can not both
+	// create the last room a final may have. The key is unique, every request creates its own room
+	var chanID string
+	full := false
+	err = g.jobs.do(ctx, fmt.Sprintf("voice-room:%d:%s", finalID, c.msg.ID), nil, func(int) error {
+		rooms, err := getVoiceChannels(g, finalID, true)
+		if err != nil {
+			return err
+		}
+		if len(rooms) >= maxVoiceRooms {
+			full = true
+			return nil
+		}
+
+		name := c.serv.voiceRoomName(g, final, rooms)
+		ch, err := c.serv.makeVoiceChan(g, name, g.finalsCatID, final.roleID)
+		if err != nil {
+			return err
+		}
+
+		if err = insertVoiceChannel(g, ch.ID, finalID, true); err != nil {
+			if _, derr := c.serv.ds.ChannelDelete(ch.ID); derr != nil {
+				return derr
+			}
+			return err
+		}
+		chanID = ch.ID
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if full {
+		return c.ReplyMsg(msgVoiceRoomLimit, maxVoiceRooms)
+	}
+
+	c.log().Info("Created voice room", "final", finalID, "room", chanID)
+	c.serv.audit(g, auditEvent{actor: c.Author().ID, action: auditVoice, target: finalTarget(finalID), after: chanID})
+	c.serv.addVoiceRoom(g, chanID)
+	return c.ReplyMsg(msgVoiceRoomCreated, "<#"+chanID+">", int(voiceRoomIdle.Minutes()))
+}
+
+func cmdFinalVoice(ctx context.Context, args []string, c *TerminalCommandContext) error {
+	t := c.term
+	g := t.origin
+
+	args, err := positional(args, 0, 1)
+	if err != nil {
+		return err
+	}
+	wasOn := g.voiceEnabled()
+	if len(args) == 0 {
+		if wasOn {
+			return t.PrintMsg(msgFinalVoiceCurrentOn)
+		}
+		return t.PrintMsg(msgFinalVoiceCurrentOff)
+	}
+
+	var on bool
+	switch strings.ToLower(args[0]) {
+	case "on":
+		on = true
+	case "off":
+	default:
+		return t.PrintMsg(msgFinalVoiceUnknown, args[0])
+	}
+
+	before, after := "off", "off"
+	if wasOn {
+		before = "on"
+	}
+	if on {
+		after = "on"
+	}
+
+	// the setting changes first, so that finals set up in the meantime follow it.
+	// It is only saved once every final has been changed, and is taken back otherwise
+	g.setVoiceEnabled(on)
+	var n int
+	if on {
+		n, err = t.serv.provisionFinalVoice(ctx, g)
+	} else {
+		n, err = t.serv.removeFinalVoice(ctx, g)
+	}
+	if err == nil {
+		err = t.serv.setGuildOption(g, "final_voice", before, after, c.Author().ID)
+	}
+	if err != nil {
+		g.setVoiceEnabled(wasOn)
+		return err
+	}
+
+	if on {
+		return t.PrintMsg(msgFinalVoiceOn, n)
+	}
+	return t.PrintMsg(msgFinalVoiceOff, n)
+}
@@ -0,0 +1,158 @@
+package schooldiscord
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestVoiceRoomLimitWithConcurrentRequests(t *testing.T) {
+	var mu sync.Mutex
+	rooms := []string{"501", "502"} // one below the limit
+
+	onSQL(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.Contains(query, "SELECT idfinal FROM final WHERE fk_idchannel = ?"):
+			return rows(nil, []interface{}{int64(5)}), nil
+		case strings.Contains(query, "WHERE final.idfinal = ?"):
+			return rows(nil, []interface{}{int64(5), "Mathematik 1", "MA1", "300", "400"}), nil
+		case strings.Contains(query, "SELECT idchannel FROM voice_channel"):
+			r := rows(nil)
+			for _, id := range rooms {
+				r.vals = append(r.vals, []driver.Value{[]byte(id)})
+			}
+			return r, nil
+		case strings.Contains(query, "INSERT INTO voice_channel"):
+			rooms = append(rooms, args[0].(string))
+		}
+		return nil, nil
+	})
+
+	clk := newFakeClock()
+	s, g, dc := newTestService(t, clk)
+	t.Cleanup(func() {
+		g.voiceMu.Lock()
+		for _, timer := range g.voiceRooms {
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+		g.voiceMu.Unlock()
+	})
+
+	creating := make(chan struct{}, 2)
+	release := make(chan struct{})
+	var creates int
+	dc.respond = func(req *http.Request) (int, string) {
+		if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/guilds/100/channels") {
+			mu.Lock()
+			creates++
+			mu.Unlock()
+			creating <- struct{}{}
+			<-release
+			return http.StatusOK, `{"id":"503"}`
+		}
+		return 0, ""
+	}
+
+	voice := func(msgID string) <-chan error {
+		res := make(chan error, 1)
+		m := userMsg(testUser, "300", "!voice")
+		m.ID = msgID
+		c := &GuildCommandContext{serv: s, g: g, msg: m}
+		go func() { res <- cmdVoice(context.Background(), nil, c) }()
+		return res
+	}
+
+	// the second request comes in while the first is creating the last room
+	first := voice("1001")
+	<-creating
+	second := voice("1002")
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	for _, res := range []<-chan error{first, second} {
+		if err := waitResult(t, res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if creates != 1 {
+		t.Errorf("%d voice rooms were created, want 1", creates)
+	}
+	if len(rooms) != maxVoiceRooms {
+		t.Errorf("final has %d voice rooms, want %d", len(rooms), maxVoiceRooms)
+	}
+	if !dc.sentContaining(tr(localeDE, msgVoiceRoomCreated, "<#503>", int(voiceRoomIdle.Minutes()))) {
+		t.Errorf("first request was not told about its room, sent: %q", dc.messages())
+	}
+	if !dc.sentContaining(tr(localeDE, msgVoiceRoomLimit, maxVoiceRooms)) {
+		t.Errorf("second request was not told about the limit, sent: %q", dc.messages())
+	}
+}
+
+func TestVoiceRoomNameTakesLowestFreeNumber(t *testing.T) {
+	onSQL(t, nil)
+	s, g, _ := newTestService(t, newFakeClock())
+	final := &modelFinal{id: 5, name: "Mathematik 1"}
+
+	// room 1 was deleted, room 2 is still there
+	if err := s.ds.State.GuildAdd(&discordgo.Guild{ID: g.id()}); err != nil {
+		t.Fatal(err)
+	}
+	room2 := tr(localeDE, msgVoiceRoomName, final.name, 2)
+	if err := s.ds.State.ChannelAdd(&discordgo.Channel{ID: "502", GuildID: g.id(), Name: room2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := s.voiceRoomName(g, final, []string{"502"}), tr(localeDE, msgVoiceRoomName, final.name, 1); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := s.voiceRoomName(g, final, nil), tr(localeDE, msgVoiceRoomName, final.name, 1); got != want {
+		t.Errorf("without rooms: got %q, want %q", got, want)
+	}
+}
+
+func TestFinalVoiceSettingRolledBackWhenProvisioningFails(t *testing.T) {
+	onSQL(t, func(query string, args []driver.Value) (*fakeRows, error) {
+		if strings.Contains(query, "fk_idchannel IS NOT NULL") {
+			return nil, driver.ErrBadConn
+		}
+		return nil, nil
+	})
+	clk := newFakeClock()
+	s, g, dc := newTestService(t, clk)
+
+	term := s.makeTerminal(testUser, testChan, termAdmin, g, time.Minute)
+	s.addTerminal(term)
+	if err := term.start(time.Minute, msgAdminGreeting); err != nil {
+		t.Fatal(err)
+	}
+	defer term.close("test over")
+	clk.waitArmed(t, time.Minute)
+
+	term.deliver(userMsg(testUser, testChan, "voice on"))
+	clk.waitArmed(t, time.Minute)
+
+	if g.voiceEnabled() {
+		t.Error("voice setting stayed on although no final got a voice channel")
+	}
+	for _, stmt := range sqlStatements() {
+		if strings.Contains(stmt, "INSERT INTO `option`") {
+			t.Error("voice setting was saved although provisioning failed")
+		}
+	}
+	if !dc.sentContaining(tr(localeDE, msgDBUnavailable)) {
+		t.Errorf("admin was not told about the failure, sent: %q", dc.messages())
+	}
+}
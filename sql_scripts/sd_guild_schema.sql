@@ -1,5 +1,6 @@
 CREATE TABLE `user` (
   `iduser` varchar(20) NOT NULL,
+  `language` varchar(5) DEFAULT NULL,
   PRIMARY KEY (`iduser`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 CREATE TABLE `role` (
@@ -59,4 +60,8 @@ CREATE TABLE `option` (
   PRIMARY KEY (`key`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 INSERT INTO `option` (`key`, `value`) VALUES ('command_prefix', '!');
-INSERT INTO `option` (`key`, `value`) VALUES ('finalsCategoryID', '');
\ No newline at end of file
+INSERT INTO `option` (`key`, `value`) VALUES ('finalsCategoryID', '');
+INSERT INTO `option` (`key`, `value`) VALUES ('language', 'de');
+INSERT INTO `option` (`key`, `value`) VALUES ('terminal_mode', 'auto');
+INSERT INTO `option` (`key`, `value`) VALUES ('audit_channel', '');
+INSERT INTO `option` (`key`, `value`) VALUES ('final_voice', 'off');
\ No newline at end of file
@@ -0,0 +1,46 @@
+SET @add_user_language = IF((SELECT COUNT(*) FROM information_schema.COLUMNS
+  WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'user' AND COLUMN_NAME = 'language') = 0,
+  'ALTER TABLE `user` ADD COLUMN `language` varchar(5) DEFAULT NULL', 'DO 0');
+PREPARE add_user_language FROM @add_user_language;
+EXECUTE add_user_language;
+DEALLOCATE PREPARE add_user_language;
+CREATE TABLE IF NOT EXISTS `audit_event` (
+  `idevent` bigint NOT NULL AUTO_INCREMENT,
+  `time` datetime NOT NULL,
+  `actor` varchar(20) NOT NULL,
+  `action` varchar(32) NOT NULL,
+  `target` varchar(64) NOT NULL,
+  `before` varchar(255) DEFAULT NULL,
+  `after` varchar(255) DEFAULT NULL,
+  PRIMARY KEY (`idevent`),
+  KEY `time_idx` (`time`),
+  KEY `actor_idx` (`actor`),
+  KEY `target_idx` (`target`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+CREATE TABLE IF NOT EXISTS `error_report` (
+  `ref` char(8) NOT NULL,
+  `time` datetime NOT NULL,
+  `iduser` varchar(20) NOT NULL,
+  `command` varchar(64) NOT NULL,
+  `input` text NOT NULL,
+  `error` text NOT NULL,
+  `stack` text NOT NULL,
+  PRIMARY KEY (`ref`),
+  KEY `time_idx` (`time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+CREATE TABLE IF NOT EXISTS `final_info` (
+  `idfinal` int NOT NULL,
+  `idmessage` varchar(20) NOT NULL,
+  `topic` varchar(1024) NOT NULL,
+  `content` text NOT NULL,
+  PRIMARY KEY (`idfinal`),
+  CONSTRAINT `final_info_final` FOREIGN KEY (`idfinal`) REFERENCES `final` (`idfinal`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+CREATE TABLE IF NOT EXISTS `voice_channel` (
+  `idchannel` varchar(20) NOT NULL,
+  `idfinal` int NOT NULL,
+  `temporary` tinyint(1) NOT NULL,
+  PRIMARY KEY (`idchannel`),
+  KEY `final_idx` (`idfinal`),
+  CONSTRAINT `voice_channel_final` FOREIGN KEY (`idfinal`) REFERENCES `final` (`idfinal`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
\ No newline at end of file
@@ -5,4 +5,17 @@ CREATE TABLE `option` (
   `set_by` varchar(128) DEFAULT NULL,
   PRIMARY KEY (`key`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
-INSERT INTO `option` (`key`, `value`) VALUES ('token', '');
\ No newline at end of file
+CREATE TABLE `terminal_session` (
+  `idchannel` varchar(20) NOT NULL,
+  `iduser` varchar(20) NOT NULL,
+  `idguild` varchar(20) NOT NULL,
+  `kind` int NOT NULL DEFAULT '0',
+  `private` tinyint NOT NULL DEFAULT '0',
+  `expires` datetime NOT NULL,
+  PRIMARY KEY (`idchannel`),
+  KEY `guild_idx` (`idguild`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+INSERT INTO `option` (`key`, `value`) VALUES ('token', '');
+INSERT INTO `option` (`key`, `value`) VALUES ('metrics_addr', '');
+INSERT INTO `option` (`key`, `value`) VALUES ('log_level', 'info');
+INSERT INTO `option` (`key`, `value`) VALUES ('log_format', 'text');
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `terminal_session` (
+  `idchannel` varchar(20) NOT NULL,
+  `iduser` varchar(20) NOT NULL,
+  `idguild` varchar(20) NOT NULL,
+  `kind` int NOT NULL DEFAULT '0',
+  `private` tinyint NOT NULL DEFAULT '0',
+  `expires` datetime NOT NULL,
+  PRIMARY KEY (`idchannel`),
+  KEY `guild_idx` (`idguild`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
\ No newline at end of file